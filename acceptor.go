@@ -1,13 +1,25 @@
 package linker
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"golang.org/x/sys/unix"
+	"io"
 	"log"
 	"net"
 	"runtime"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type Acceptor interface {
 	Listen(addr string) error
+	// Addr 返回监听的地址，监听端口为0时可以获取实际分配的端口，未监听时返回nil
+	Addr() net.Addr
+	// Close 停止接收新的连接，返回前等待accept协程和进行中的握手退出
+	Close() error
 }
 
 type acceptor struct {
@@ -16,6 +28,12 @@ type acceptor struct {
 	receive        int
 	keepalive      bool
 	connDispatcher func(conn net.Conn)
+
+	mu        sync.Mutex
+	listeners []io.Closer
+	addr      net.Addr
+	done      chan struct{}
+	wg        sync.WaitGroup // accept、握手等协程，Close时等待退出
 }
 
 func newAcceptor(dispatcher func(conn net.Conn)) *acceptor {
@@ -25,7 +43,52 @@ func newAcceptor(dispatcher func(conn net.Conn)) *acceptor {
 		receive:        4096,
 		keepalive:      false,
 		connDispatcher: dispatcher,
+		done:           make(chan struct{}),
+	}
+}
+
+func (loop *acceptor) addListener(lis io.Closer, addr net.Addr) {
+	loop.mu.Lock()
+	loop.listeners = append(loop.listeners, lis)
+	if loop.addr == nil {
+		loop.addr = addr
 	}
+	loop.mu.Unlock()
+}
+
+func (loop *acceptor) Addr() net.Addr {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	return loop.addr
+}
+
+// Close 关闭所有监听并等待accept协程退出，之后不会再有新连接交给dispatcher
+func (loop *acceptor) Close() (err error) {
+	loop.mu.Lock()
+	select {
+	case <-loop.done:
+		loop.mu.Unlock()
+		return nil
+	default:
+		close(loop.done)
+	}
+	for _, lis := range loop.listeners {
+		if closeErr := lis.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	loop.mu.Unlock()
+	loop.wg.Wait()
+	return
+}
+
+// spawn 启动由Close等待退出的协程
+func (loop *acceptor) spawn(fn func()) {
+	loop.wg.Add(1)
+	go func() {
+		defer loop.wg.Done()
+		fn()
+	}()
 }
 
 func (loop *acceptor) WithReadBuffer(bytes int) *acceptor {
@@ -52,9 +115,10 @@ func (loop TCPAcceptor) Listen(bind string) (err error) {
 	if err != nil {
 		return
 	}
+	loop.addListener(lis, lis.Addr())
 
 	for i := 0; i < loop.core; i++ {
-		go loop.accept(lis)
+		loop.spawn(func() { loop.accept(lis) })
 	}
 	return
 }
@@ -68,19 +132,25 @@ func (loop TCPAcceptor) accept(lis *net.TCPListener) {
 	for {
 		if conn, err = lis.AcceptTCP(); err != nil {
 			// if listener close then return
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			log.Printf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
 			continue
 		}
 		if err = conn.SetKeepAlive(loop.keepalive); err != nil {
 			log.Printf("conn.SetKeepAlive() error(%v)", err)
+			_ = conn.Close()
 			continue
 		}
 		if err = conn.SetReadBuffer(loop.receive); err != nil {
 			log.Printf("conn.SetReadBuffer() error(%v)", err)
+			_ = conn.Close()
 			continue
 		}
 		if err = conn.SetWriteBuffer(loop.send); err != nil {
 			log.Printf("conn.SetWriteBuffer() error(%v)", err)
+			_ = conn.Close()
 			continue
 		}
 
@@ -89,55 +159,189 @@ func (loop TCPAcceptor) accept(lis *net.TCPListener) {
 
 }
 
+// UDPAcceptor 以数据报的方式处理UDP，每个远端地址对应一个虚拟连接
 type UDPAcceptor struct {
 	*acceptor
+	reusePort        int           // 开启SO_REUSEPORT时的socket数量
+	idleTimeout      time.Duration // 会话的空闲超时时间
+	maxPacketSize    int
+	sessionConnected func(conn *UDPConn)
+	packetDispatcher func(conn Conn, packet []byte)
+}
+
+func (loop *UDPAcceptor) WithReusePort(n int) *UDPAcceptor {
+	loop.reusePort = n
+	return loop
+}
+
+func (loop *UDPAcceptor) WithIdleTimeout(timeout time.Duration) *UDPAcceptor {
+	loop.idleTimeout = timeout
+	return loop
 }
 
-func (loop UDPAcceptor) Listen(bind string) (err error) {
-	addr, err := net.ResolveUDPAddr("udp", bind)
+func (loop *UDPAcceptor) Listen(bind string) (err error) {
+	n := 1
+	config := net.ListenConfig{}
+	if loop.reusePort > 1 {
+		n = loop.reusePort
+		config.Control = reusePortControl
+	}
+
+	listeners := make([]*udpListener, 0, n)
+	for i := 0; i < n; i++ {
+		var packetConn net.PacketConn
+		if packetConn, err = config.ListenPacket(context.Background(), "udp", bind); err != nil {
+			log.Printf("net.ListenUDP(udp, %s) error(%v)", bind, err)
+			break
+		}
+		socket := packetConn.(*net.UDPConn)
+		// 端口为0时其余socket复用第一个socket分配到的端口
+		bind = socket.LocalAddr().String()
+		// 一个socket承载所有会话，未设置时使用内核默认的缓冲区大小
+		if loop.receive > 0 {
+			if err = socket.SetReadBuffer(loop.receive); err != nil {
+				_ = socket.Close()
+				break
+			}
+		}
+		if loop.send > 0 {
+			if err = socket.SetWriteBuffer(loop.send); err != nil {
+				_ = socket.Close()
+				break
+			}
+		}
+		listeners = append(listeners, newUDPListener(socket))
+	}
 	if err != nil {
-		log.Printf("net.ResolveTCPAddr(tcp, %s) error(%v)", bind, err)
+		for _, lis := range listeners {
+			_ = lis.socket.Close()
+		}
 		return
 	}
 
-	for i := 0; i < loop.core; i++ {
-		go loop.accept(addr)
+	for _, lis := range listeners {
+		loop.addListener(lis.socket, lis.socket.LocalAddr())
+		lis := lis
+		loop.spawn(func() { loop.accept(lis) })
+		if loop.idleTimeout > 0 {
+			loop.spawn(func() { loop.expire(lis) })
+		}
 	}
-
 	return
 }
-func (loop UDPAcceptor) accept(addr *net.UDPAddr) {
-	var (
-		conn *net.UDPConn
-		err  error
-	)
 
+func (loop *UDPAcceptor) accept(lis *udpListener) {
+	buf := make([]byte, loop.maxPacketSize)
 	for {
-		if conn, err = net.ListenUDP("udp", addr); err != nil {
-			log.Printf("net.ListenUDP(udp, %s) error(%v)", addr.IP, err)
+		n, remote, err := lis.socket.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("conn.ReadFromUDP(\"%s\") error(%v)", lis.socket.LocalAddr().String(), err)
 			continue
 		}
 
-		if err = conn.SetReadBuffer(loop.receive); err != nil {
-			log.Printf("conn.SetReadBuffer() error(%v)", err)
-			continue
+		conn, created := lis.session(remote)
+		conn.active(time.Now().UnixNano())
+		if created {
+			loop.sessionConnected(conn)
 		}
-		if err = conn.SetWriteBuffer(loop.send); err != nil {
-			log.Printf("conn.SetWriteBuffer() error(%v)", err)
+		if n == 0 {
 			continue
 		}
 
-		loop.connDispatcher(conn)
+		// 缓冲区会被复用，交给业务处理前需要拷贝
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+		loop.packetDispatcher(conn, packet)
+	}
+}
+
+// expire 定时清理空闲的会话，idleTimeout不大于0时不清理
+func (loop *UDPAcceptor) expire(lis *udpListener) {
+	ticker := time.NewTicker(loop.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lis.expire(loop.idleTimeout)
+		case <-loop.done:
+			return
+		}
+	}
+}
 
+func reusePortControl(network, address string, c syscall.RawConn) (err error) {
+	controlErr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if controlErr != nil {
+		return controlErr
 	}
+	return
 }
 
+// WebsocketAcceptor 在TCP连接上完成websocket握手后再交给reactor
 type WebsocketAcceptor struct {
+	*TCPAcceptor
+	handshakeTimeout  time.Duration
+	upgradeDispatcher func(conn net.Conn, reader *bufio.Reader)
+}
+
+func (loop *WebsocketAcceptor) WithHandshakeTimeout(timeout time.Duration) *WebsocketAcceptor {
+	loop.handshakeTimeout = timeout
+	return loop
+}
+
+// handshake 握手需要读取http请求，放在单独的协程里，避免阻塞accept。
+// Close时中断进行中的握手，Close返回后不会再有连接交给upgradeDispatcher
+func (loop *WebsocketAcceptor) handshake(conn net.Conn) {
+	loop.spawn(func() {
+		if err := conn.SetDeadline(time.Now().Add(loop.handshakeTimeout)); err != nil {
+			_ = conn.Close()
+			return
+		}
+		finished := make(chan struct{})
+		defer close(finished)
+		go func() {
+			select {
+			case <-loop.done:
+				_ = conn.SetDeadline(time.Now())
+			case <-finished:
+			}
+		}()
+
+		reader, err := upgrade(conn)
+		if err != nil {
+			log.Printf("websocket upgrade(\"%s\") error(%v)", conn.RemoteAddr().String(), err)
+			_ = conn.Close()
+			return
+		}
+		if err = conn.SetDeadline(time.Time{}); err != nil {
+			_ = conn.Close()
+			return
+		}
+		loop.upgradeDispatcher(conn, reader)
+	})
 }
 
 func NewTCPAcceptor(dispatcher func(conn net.Conn)) *TCPAcceptor {
 	return &TCPAcceptor{acceptor: newAcceptor(dispatcher)}
 }
-func NewUDPAcceptor(dispatcher func(conn net.Conn)) *UDPAcceptor {
-	return &UDPAcceptor{acceptor: newAcceptor(dispatcher)}
+func NewUDPAcceptor(connected func(conn *UDPConn), dispatcher func(conn Conn, packet []byte)) *UDPAcceptor {
+	base := newAcceptor(nil)
+	base.receive, base.send = 0, 0
+	return &UDPAcceptor{
+		acceptor:         base,
+		idleTimeout:      time.Minute,
+		maxPacketSize:    65535,
+		sessionConnected: connected,
+		packetDispatcher: dispatcher,
+	}
+}
+func NewWebsocketAcceptor(dispatcher func(conn net.Conn, reader *bufio.Reader)) *WebsocketAcceptor {
+	loop := &WebsocketAcceptor{handshakeTimeout: 10 * time.Second, upgradeDispatcher: dispatcher}
+	loop.TCPAcceptor = NewTCPAcceptor(loop.handshake)
+	return loop
 }
@@ -0,0 +1,100 @@
+package linker
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// sharedMessage 广播的消息，每种封帧方式只编码一次，编码结果被所有连接的发送队列共享
+type sharedMessage struct {
+	body []byte
+
+	streamOnce sync.Once
+	stream     []byte
+	streamErr  error
+
+	websocketOnce sync.Once
+	websocket     []byte
+}
+
+// sharedPusher 支持共享编码结果的连接
+type sharedPusher interface {
+	pushShared(msg *sharedMessage) error
+}
+
+// encode 同一个reactor中的连接使用同一个编解码器，只需要编码一次
+func (msg *sharedMessage) encode(codec Codec) ([]byte, error) {
+	msg.streamOnce.Do(func() {
+		msg.stream, msg.streamErr = codec.Encode(msg.body)
+	})
+	return msg.stream, msg.streamErr
+}
+
+// websocketFrame 同一个reactor中的连接使用相同的帧类型，只编码一次
+func (msg *sharedMessage) websocketFrame(opcode byte) []byte {
+	msg.websocketOnce.Do(func() {
+		msg.websocket = encodeFrame(opcode, msg.body)
+	})
+	return msg.websocket
+}
+
+// Broadcast 向所有连接发送消息，返回成功放入发送队列和被丢弃的数量。
+// 编解码器封帧失败时返回错误
+func (reactor *MainReactor) Broadcast(msg []byte) (delivered, dropped int, err error) {
+	return reactor.fanout(reactor.registry.snapshot(), msg)
+}
+
+// Multicast 向指定ID的连接发送消息，不存在的连接计入丢弃的数量
+func (reactor *MainReactor) Multicast(ids []string, msg []byte) (delivered, dropped int, err error) {
+	connections := make([]Conn, 0, len(ids))
+	for _, id := range ids {
+		if conn := reactor.registry.get(id); conn != nil {
+			connections = append(connections, conn)
+		}
+	}
+	delivered, dropped, err = reactor.fanout(connections, msg)
+	return delivered, dropped + len(ids) - len(connections), err
+}
+
+// fanout 按照SubReactor对连接分组，各组并行发送
+func (reactor *MainReactor) fanout(connections []Conn, body []byte) (int, int, error) {
+	groups := make([][]Conn, len(reactor.children))
+	for _, conn := range connections {
+		i := reactor.shardOf(conn)
+		groups[i] = append(groups[i], conn)
+	}
+
+	msg := &sharedMessage{body: body}
+	var (
+		wg        sync.WaitGroup
+		delivered int64
+		dropped   int64
+	)
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(group []Conn) {
+			defer wg.Done()
+			var ok, failed int64
+			for _, conn := range group {
+				var err error
+				if pusher, is := conn.(sharedPusher); is {
+					err = pusher.pushShared(msg)
+				} else {
+					err = conn.Push(body)
+				}
+				if err != nil {
+					failed++
+				} else {
+					ok++
+				}
+			}
+			atomic.AddInt64(&delivered, ok)
+			atomic.AddInt64(&dropped, failed)
+		}(group)
+	}
+	wg.Wait()
+	return int(delivered), int(dropped), msg.streamErr
+}
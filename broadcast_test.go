@@ -0,0 +1,46 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	readers := make([]*bufio.Reader, 0, 3)
+	for i := 0; i < 3; i++ {
+		c, err := net.DialTimeout("tcp", bind, time.Second)
+		assert.Nil(t, err)
+		defer c.Close()
+		_ = c.SetReadDeadline(time.Now().Add(time.Second))
+		readers = append(readers, bufio.NewReader(c))
+	}
+	waitFor(func() bool { return reactor.Count() == 3 })
+	assert.Equal(t, 3, reactor.Count())
+
+	delivered, dropped, err := reactor.Broadcast([]byte("score"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, delivered)
+	assert.Equal(t, 0, dropped)
+	for _, reader := range readers {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, "score\n", line)
+	}
+
+	var id string
+	reactor.Range(func(conn Conn) bool {
+		id = conn.ID()
+		return false
+	})
+	delivered, dropped, err = reactor.Multicast([]string{id, "missing"}, []byte("goal"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, 1, dropped)
+}
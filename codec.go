@@ -0,0 +1,517 @@
+package linker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"linker/pkg/binary"
+	"math"
+	"strconv"
+)
+
+var (
+	ErrFrameLength = errors.New("codec: invalid frame length")
+	ErrFrameHeader = errors.New("codec: invalid frame header")
+)
+
+// InboundBuffer 解码器读取数据的缓冲区，buffer.RingBuffer实现了该接口
+type InboundBuffer interface {
+	// Peek 读取最多n个字节但不移动读指针，数据跨越数组末端时分为head和tail两段
+	Peek(n int) (head []byte, tail []byte)
+	Discard(n int) (int, error)
+	Buffered() int
+}
+
+// Codec 负责从缓冲区中切分出完整的帧，以及对发送的消息进行封帧
+// Codec会被所有连接共享，实现时不能保存连接相关的状态
+type Codec interface {
+	// Decode 解码一个完整的帧，数据不足时返回nil，返回的帧不能引用缓冲区的内存
+	Decode(in InboundBuffer) ([]byte, error)
+	// Encode 对消息进行封帧
+	Encode(msg []byte) ([]byte, error)
+}
+
+// FrameLengthDecoder 可选接口，在帧头完整时就能得到帧的总长度，用于提前识别超长帧
+type FrameLengthDecoder interface {
+	// FrameLength 返回下一个帧的总长度，帧头不完整时返回-1
+	FrameLength(in InboundBuffer) (int, error)
+}
+
+// Header 解码后的帧中的帧头
+type Header struct {
+	Route    Route  // 路由键，零值表示没有路由
+	Sequence uint32 // 序列号，响应中带有与请求相同的序列号
+	Response bool   // 是否为响应，客户端请求和服务端请求的序列号相互独立
+}
+
+// HeaderCodec 可选接口，由Codec实现，解析和生成解码后的帧中的帧头
+type HeaderCodec interface {
+	// DecodeHeader 解析帧头，返回去掉帧头的消息体
+	DecodeHeader(frame []byte) (header Header, body []byte, err error)
+	// EncodeHeader 在消息前加上帧头，返回的消息再经过Encode封帧
+	EncodeHeader(header Header, msg []byte) ([]byte, error)
+	// MaxSequence 序列号的最大值，为0表示帧头中没有序列号
+	MaxSequence() uint32
+}
+
+// FrameTooLargeError 帧的长度超过了WithMaxFrameSize或者编解码器的限制，按WithOversizePolicy处理
+type FrameTooLargeError struct {
+	Length int // 帧的总长度，无法得知时为-1
+	Limit  int
+}
+
+func (err *FrameTooLargeError) Error() string {
+	if err.Length < 0 {
+		return fmt.Sprintf("codec: frame exceeds %d bytes", err.Limit)
+	}
+	return fmt.Sprintf("codec: frame length %d exceeds %d bytes", err.Length, err.Limit)
+}
+
+// DelimiterCodec 以分隔符切分帧，帧内容不包括分隔符
+type DelimiterCodec struct {
+	delimiter byte
+	separator byte // 主题与消息体之间的分隔符
+	topic     bool // 是否以帧开头的主题作为路由
+	sequence  bool // 是否带有序列号
+}
+
+func NewDelimiterCodec(delimiter byte) *DelimiterCodec {
+	return &DelimiterCodec{delimiter: delimiter}
+}
+
+func (codec *DelimiterCodec) Decode(in InboundBuffer) ([]byte, error) {
+	index := indexByte(in, codec.delimiter)
+	if index < 0 {
+		return nil, nil
+	}
+	frame := readFull(in, index)
+	_, _ = in.Discard(1)
+	return frame, nil
+}
+
+func (codec *DelimiterCodec) Encode(msg []byte) ([]byte, error) {
+	frame := make([]byte, len(msg)+1)
+	copy(frame, msg)
+	frame[len(msg)] = codec.delimiter
+	return frame, nil
+}
+
+// WithTopicSeparator 以帧开头到separator之间的内容作为主题路由，没有separator时整个帧都是主题
+func (codec *DelimiterCodec) WithTopicSeparator(separator byte) *DelimiterCodec {
+	codec.separator = separator
+	codec.topic = true
+	return codec
+}
+
+// WithSequence 主题之后是十进制的序列号，以空格与消息体分隔，未开启主题路由时序列号位于帧开头，
+// 响应的序列号以#开头
+func (codec *DelimiterCodec) WithSequence() *DelimiterCodec {
+	codec.sequence = true
+	return codec
+}
+
+func (codec *DelimiterCodec) DecodeHeader(frame []byte) (header Header, body []byte, err error) {
+	body = frame
+	if codec.topic {
+		index := bytes.IndexByte(body, codec.separator)
+		if index < 0 {
+			index = len(body)
+		}
+		header.Route = TopicRoute(string(body[:index]))
+		body = body[minInt(index+1, len(body)):]
+	}
+	if codec.sequence {
+		index := bytes.IndexByte(body, ' ')
+		if index < 0 {
+			index = len(body)
+		}
+		field := body[:index]
+		if len(field) > 0 && field[0] == '#' {
+			header.Response = true
+			field = field[1:]
+		}
+		sequence, err := strconv.ParseUint(string(field), 10, 32)
+		if err != nil {
+			return Header{}, nil, ErrFrameHeader
+		}
+		header.Sequence = uint32(sequence)
+		body = body[minInt(index+1, len(body)):]
+	}
+	return header, body, nil
+}
+
+func (codec *DelimiterCodec) EncodeHeader(header Header, msg []byte) ([]byte, error) {
+	out := make([]byte, 0, len(msg)+16)
+	if codec.topic {
+		topic, ok := header.Route.Topic()
+		if !ok && header.Route != (Route{}) {
+			return nil, ErrFrameHeader
+		}
+		out = append(out, topic...)
+		out = append(out, codec.separator)
+	}
+	if codec.sequence {
+		if header.Response {
+			out = append(out, '#')
+		}
+		out = strconv.AppendUint(out, uint64(header.Sequence), 10)
+		out = append(out, ' ')
+	}
+	return append(out, msg...), nil
+}
+
+func (codec *DelimiterCodec) MaxSequence() uint32 {
+	if !codec.sequence {
+		return 0
+	}
+	return math.MaxUint32
+}
+
+// LineCodec 以换行符切分帧，兼容\r\n结尾
+type LineCodec struct {
+	DelimiterCodec
+}
+
+func NewLineCodec() *LineCodec {
+	return &LineCodec{DelimiterCodec{delimiter: '\n'}}
+}
+
+func (codec *LineCodec) WithTopicSeparator(separator byte) *LineCodec {
+	codec.DelimiterCodec.WithTopicSeparator(separator)
+	return codec
+}
+
+func (codec *LineCodec) WithSequence() *LineCodec {
+	codec.DelimiterCodec.WithSequence()
+	return codec
+}
+
+func (codec *LineCodec) Decode(in InboundBuffer) ([]byte, error) {
+	frame, err := codec.DelimiterCodec.Decode(in)
+	if n := len(frame); n > 0 && frame[n-1] == '\r' {
+		frame = frame[:n-1]
+	}
+	return frame, err
+}
+
+// FixedLengthCodec 每个帧都是固定长度
+type FixedLengthCodec struct {
+	length int
+}
+
+func NewFixedLengthCodec(length int) *FixedLengthCodec {
+	return &FixedLengthCodec{length: length}
+}
+
+func (codec *FixedLengthCodec) FrameLength(in InboundBuffer) (int, error) {
+	return codec.length, nil
+}
+
+func (codec *FixedLengthCodec) Decode(in InboundBuffer) ([]byte, error) {
+	if in.Buffered() < codec.length {
+		return nil, nil
+	}
+	return readFull(in, codec.length), nil
+}
+
+// Encode 不足长度的消息以0补齐，超出长度返回错误
+func (codec *FixedLengthCodec) Encode(msg []byte) ([]byte, error) {
+	if len(msg) > codec.length {
+		return nil, ErrFrameLength
+	}
+	frame := make([]byte, codec.length)
+	copy(frame, msg)
+	return frame, nil
+}
+
+// LengthFieldCodec 根据帧头中的长度字段切分帧，参考netty的LengthFieldBasedFrameDecoder
+//
+// 帧的总长度 = offset + width + 长度字段的值 + adjustment，
+// 解码时丢弃帧开头的strip个字节
+type LengthFieldCodec struct {
+	offset         int // 长度字段的偏移量
+	width          int // 长度字段占用的字节数: 1,2,4,8
+	adjustment     int // 长度字段的值与帧长度之间的差值
+	strip          int // 解码后需要丢弃的字节数
+	maxFrameLength int
+	order          binary.ByteOrder
+	command        headerField // 命令ID在解码后的帧中的位置
+	sequence       headerField // 序列号在解码后的帧中的位置
+}
+
+// NewLengthFieldCodec 默认为大端序，解码后保留帧头
+func NewLengthFieldCodec(offset, width int) *LengthFieldCodec {
+	switch width {
+	case 1, 2, 4, 8:
+	default:
+		panic("codec: length field width must be 1, 2, 4 or 8")
+	}
+	return &LengthFieldCodec{
+		offset:         offset,
+		width:          width,
+		maxFrameLength: math.MaxInt32,
+		order:          binary.BigEndian,
+	}
+}
+
+// NewLengthPrefixCodec 以4字节大端序的长度作为帧头，长度不包括帧头，解码后去掉帧头
+func NewLengthPrefixCodec() *LengthFieldCodec {
+	return NewLengthFieldCodec(0, 4).WithStrip(4)
+}
+
+func (codec *LengthFieldCodec) WithByteOrder(order binary.ByteOrder) *LengthFieldCodec {
+	codec.order = order
+	return codec
+}
+
+func (codec *LengthFieldCodec) WithAdjustment(adjustment int) *LengthFieldCodec {
+	codec.adjustment = adjustment
+	return codec
+}
+
+func (codec *LengthFieldCodec) WithStrip(strip int) *LengthFieldCodec {
+	codec.strip = strip
+	return codec
+}
+
+func (codec *LengthFieldCodec) WithMaxFrameLength(length int) *LengthFieldCodec {
+	codec.maxFrameLength = length
+	return codec
+}
+
+// WithCommandField 从解码后的帧中offset处读取width字节的命令ID作为路由
+func (codec *LengthFieldCodec) WithCommandField(offset, width int) *LengthFieldCodec {
+	codec.command = newHeaderField(offset, width)
+	return codec
+}
+
+// WithSequenceField 从解码后的帧中offset处读取width字节的序列号，最高位表示该帧是否为响应
+func (codec *LengthFieldCodec) WithSequenceField(offset, width int) *LengthFieldCodec {
+	codec.sequence = newHeaderField(offset, width)
+	return codec
+}
+
+// headerLength 帧头的长度，命令ID和序列号之后为消息体
+func (codec *LengthFieldCodec) headerLength() int {
+	return maxInt(codec.command.end(), codec.sequence.end())
+}
+
+func (codec *LengthFieldCodec) DecodeHeader(frame []byte) (header Header, body []byte, err error) {
+	length := codec.headerLength()
+	if len(frame) < length {
+		return Header{}, nil, ErrFrameHeader
+	}
+	if codec.command.width > 0 {
+		header.Route = CommandRoute(codec.command.get(codec.order, frame))
+	}
+	if codec.sequence.width > 0 {
+		value := codec.sequence.get(codec.order, frame)
+		header.Sequence = value & codec.MaxSequence()
+		header.Response = value != header.Sequence
+	}
+	return header, frame[length:], nil
+}
+
+// EncodeHeader 帧头中命令ID和序列号之外的字节填0
+func (codec *LengthFieldCodec) EncodeHeader(header Header, msg []byte) ([]byte, error) {
+	length := codec.headerLength()
+	out := make([]byte, length+len(msg))
+	if codec.command.width > 0 {
+		command, ok := header.Route.Command()
+		if !ok && header.Route != (Route{}) {
+			return nil, ErrFrameHeader
+		}
+		codec.command.put(codec.order, out, command)
+	}
+	if codec.sequence.width > 0 {
+		max := codec.MaxSequence()
+		if header.Sequence > max {
+			return nil, ErrFrameHeader
+		}
+		value := header.Sequence
+		if header.Response {
+			value |= max + 1
+		}
+		codec.sequence.put(codec.order, out, value)
+	}
+	copy(out[length:], msg)
+	return out, nil
+}
+
+// MaxSequence 序列号字段去掉最高位后能表示的最大值
+func (codec *LengthFieldCodec) MaxSequence() uint32 {
+	if codec.sequence.width == 0 {
+		return 0
+	}
+	return uint32(1)<<(codec.sequence.width*8-1) - 1
+}
+
+func (codec *LengthFieldCodec) FrameLength(in InboundBuffer) (int, error) {
+	return codec.frameLength(in, in.Buffered())
+}
+
+func (codec *LengthFieldCodec) Decode(in InboundBuffer) ([]byte, error) {
+	buffered := in.Buffered()
+	length, err := codec.frameLength(in, buffered)
+	if err != nil || length < 0 || buffered < length {
+		return nil, err
+	}
+
+	_, _ = in.Discard(codec.strip)
+	return readFull(in, length-codec.strip), nil
+}
+
+// frameLength 根据帧头计算帧的总长度，帧头不完整时返回-1
+func (codec *LengthFieldCodec) frameLength(in InboundBuffer, buffered int) (int, error) {
+	headerLength := codec.offset + codec.width
+	if buffered < headerLength {
+		return -1, nil
+	}
+
+	header := peekFull(in, headerLength)[codec.offset:]
+	var value uint64
+	switch codec.width {
+	case 1:
+		value = uint64(uint8(codec.order.Int8(header)))
+	case 2:
+		value = uint64(uint16(codec.order.Int16(header)))
+	case 4:
+		value = uint64(uint32(codec.order.Int32(header)))
+	case 8:
+		value = uint64(codec.order.Int64(header))
+	}
+	if value > uint64(codec.maxFrameLength) {
+		// 长度字段过大时无法得知帧的总长度
+		length := -1
+		if value < math.MaxInt32 {
+			length = int(value) + headerLength + codec.adjustment
+		}
+		return 0, &FrameTooLargeError{Length: length, Limit: codec.maxFrameLength}
+	}
+
+	length := int(value) + headerLength + codec.adjustment
+	if length < headerLength || length < codec.strip {
+		return 0, ErrFrameLength
+	}
+	if length > codec.maxFrameLength {
+		return 0, &FrameTooLargeError{Length: length, Limit: codec.maxFrameLength}
+	}
+	return length, nil
+}
+
+// Encode 帧头为offset个0字节和长度字段，之后是消息内容
+func (codec *LengthFieldCodec) Encode(msg []byte) ([]byte, error) {
+	headerLength := codec.offset + codec.width
+	value := len(msg) - codec.adjustment
+	if value < 0 || headerLength+len(msg) > codec.maxFrameLength {
+		return nil, ErrFrameLength
+	}
+
+	frame := make([]byte, headerLength+len(msg))
+	field := frame[codec.offset:headerLength]
+	switch codec.width {
+	case 1:
+		if value > math.MaxUint8 {
+			return nil, ErrFrameLength
+		}
+		codec.order.PutInt8(field, int8(value))
+	case 2:
+		if value > math.MaxUint16 {
+			return nil, ErrFrameLength
+		}
+		codec.order.PutInt16(field, int16(value))
+	case 4:
+		if uint64(value) > math.MaxUint32 {
+			return nil, ErrFrameLength
+		}
+		codec.order.PutInt32(field, int32(value))
+	case 8:
+		codec.order.PutInt64(field, int64(value))
+	}
+	copy(frame[headerLength:], msg)
+	return frame, nil
+}
+
+// headerField 帧头中的整数字段，width为0表示没有该字段
+type headerField struct {
+	offset int
+	width  int // 1,2,4
+}
+
+func newHeaderField(offset, width int) headerField {
+	switch width {
+	case 1, 2, 4:
+	default:
+		panic("codec: header field width must be 1, 2 or 4")
+	}
+	return headerField{offset: offset, width: width}
+}
+
+func (field headerField) end() int {
+	if field.width == 0 {
+		return 0
+	}
+	return field.offset + field.width
+}
+
+func (field headerField) get(order binary.ByteOrder, header []byte) uint32 {
+	b := header[field.offset:field.end()]
+	switch field.width {
+	case 1:
+		return uint32(uint8(order.Int8(b)))
+	case 2:
+		return uint32(uint16(order.Int16(b)))
+	default:
+		return uint32(order.Int32(b))
+	}
+}
+
+func (field headerField) put(order binary.ByteOrder, header []byte, value uint32) {
+	b := header[field.offset:field.end()]
+	switch field.width {
+	case 1:
+		order.PutInt8(b, int8(value))
+	case 2:
+		order.PutInt16(b, int16(value))
+	default:
+		order.PutInt32(b, int32(value))
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// peekFull 拷贝缓冲区的前n个字节，调用方需保证数据足够
+func peekFull(in InboundBuffer, n int) []byte {
+	p := make([]byte, n)
+	if n == 0 {
+		return p
+	}
+	head, tail := in.Peek(n)
+	m := copy(p, head)
+	copy(p[m:], tail)
+	return p
+}
+
+// readFull 拷贝并丢弃缓冲区的前n个字节
+func readFull(in InboundBuffer, n int) []byte {
+	p := peekFull(in, n)
+	_, _ = in.Discard(n)
+	return p
+}
+
+// indexByte 查找c在缓冲区中第一次出现的位置
+func indexByte(in InboundBuffer, c byte) int {
+	head, tail := in.Peek(0)
+	if i := bytes.IndexByte(head, c); i >= 0 {
+		return i
+	}
+	if i := bytes.IndexByte(tail, c); i >= 0 {
+		return len(head) + i
+	}
+	return -1
+}
@@ -0,0 +1,160 @@
+package linker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"linker/pkg/binary"
+	"linker/pkg/buffer"
+	"testing"
+)
+
+func TestDelimiterCodec(t *testing.T) {
+	codec := NewLineCodec()
+	in := buffer.NewRingBuffer(16)
+	_, _ = in.Write([]byte("hello\r\nwor"))
+
+	frame, err := codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(frame))
+
+	frame, err = codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+
+	_, _ = in.Write([]byte("ld\n\n"))
+	frame, _ = codec.Decode(in)
+	assert.Equal(t, "world", string(frame))
+	frame, _ = codec.Decode(in)
+	assert.NotNil(t, frame)
+	assert.Empty(t, frame)
+	assert.True(t, in.IsEmpty())
+
+	encoded, _ := codec.Encode([]byte("hello"))
+	assert.Equal(t, "hello\n", string(encoded))
+}
+
+func TestFixedLengthCodec(t *testing.T) {
+	codec := NewFixedLengthCodec(4)
+	in := buffer.NewRingBuffer(16)
+	_, _ = in.Write([]byte("abcdefg"))
+
+	frame, _ := codec.Decode(in)
+	assert.Equal(t, "abcd", string(frame))
+	frame, _ = codec.Decode(in)
+	assert.Nil(t, frame)
+
+	encoded, err := codec.Encode([]byte("ab"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{'a', 'b', 0, 0}, encoded)
+	_, err = codec.Encode([]byte("abcde"))
+	assert.Equal(t, ErrFrameLength, err)
+}
+
+func TestLengthPrefixCodec(t *testing.T) {
+	codec := NewLengthPrefixCodec()
+	in := buffer.NewRingBuffer(16)
+
+	// 让帧跨越环形缓冲区的末端
+	_, _ = in.Write(make([]byte, 10))
+	_, _ = in.Discard(10)
+
+	encoded, _ := codec.Encode([]byte("hello,world"))
+	_, _ = in.Write(encoded[:8])
+	frame, err := codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+
+	_, _ = in.Write(encoded[8:])
+	frame, err = codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello,world", string(frame))
+	assert.True(t, in.IsEmpty())
+}
+
+func TestLengthFieldCodec(t *testing.T) {
+	// 1字节类型 + 2字节小端序长度(包括帧头) + 负载，解码后保留帧头
+	codec := NewLengthFieldCodec(1, 2).
+		WithByteOrder(binary.LittleEndian).
+		WithAdjustment(-3)
+	in := buffer.NewRingBuffer(8)
+
+	encoded, err := codec.Encode([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 8, 0, 'h', 'e', 'l', 'l', 'o'}, encoded)
+
+	_, _ = in.Write([]byte{1, 2, 3, 4, 5})
+	_, _ = in.Discard(5)
+	encoded[0] = 7
+	_, _ = in.Write(encoded[:2])
+	frame, err := codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+
+	_, _ = in.Write(encoded[2:])
+	frame, err = codec.Decode(in)
+	assert.Nil(t, err)
+	assert.Equal(t, encoded, frame)
+	assert.True(t, in.IsEmpty())
+}
+
+func TestLengthFieldCodecMaxFrameLength(t *testing.T) {
+	codec := NewLengthFieldCodec(0, 8).WithStrip(8).WithMaxFrameLength(16)
+	in := buffer.NewRingBuffer(16)
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutInt64(header, 100)
+	_, _ = in.Write(header)
+	_, err := codec.Decode(in)
+	assert.Equal(t, &FrameTooLargeError{Length: 108, Limit: 16}, err)
+
+	_, err = codec.Encode(make([]byte, 9))
+	assert.Equal(t, ErrFrameLength, err)
+}
+
+func TestLengthFieldCodecHeader(t *testing.T) {
+	// 帧头: 命令ID(2) 序列号(4)
+	codec := NewLengthPrefixCodec().WithCommandField(0, 2).WithSequenceField(2, 4)
+	msg, err := codec.EncodeHeader(Header{Route: CommandRoute(7), Sequence: 42}, []byte("hi"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 7, 0, 0, 0, 42, 'h', 'i'}, msg)
+
+	header, body, err := codec.DecodeHeader(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, Header{Route: CommandRoute(7), Sequence: 42}, header)
+	assert.Equal(t, "hi", string(body))
+
+	_, _, err = codec.DecodeHeader(msg[:5])
+	assert.Equal(t, ErrFrameHeader, err)
+	_, err = codec.EncodeHeader(Header{Route: TopicRoute("chat")}, nil)
+	assert.Equal(t, ErrFrameHeader, err)
+}
+
+func TestDelimiterCodecSequence(t *testing.T) {
+	codec := NewLineCodec().WithTopicSeparator(':').WithSequence()
+	header, body, err := codec.DecodeHeader([]byte("chat:12 hello world"))
+	assert.Nil(t, err)
+	assert.Equal(t, Header{Route: TopicRoute("chat"), Sequence: 12}, header)
+	assert.Equal(t, "hello world", string(body))
+
+	msg, _ := codec.EncodeHeader(header, []byte("ok"))
+	assert.Equal(t, "chat:12 ok", string(msg))
+
+	_, _, err = codec.DecodeHeader([]byte("chat:abc hello"))
+	assert.Equal(t, ErrFrameHeader, err)
+}
+
+func TestHeaderResponse(t *testing.T) {
+	codec := NewLengthPrefixCodec().WithSequenceField(0, 2)
+	msg, err := codec.EncodeHeader(Header{Sequence: 3, Response: true}, []byte("ok"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x80, 3, 'o', 'k'}, msg)
+	header, _, _ := codec.DecodeHeader(msg)
+	assert.Equal(t, Header{Sequence: 3, Response: true}, header)
+	_, err = codec.EncodeHeader(Header{Sequence: 1 << 15}, nil)
+	assert.Equal(t, ErrFrameHeader, err)
+
+	text := NewLineCodec().WithSequence()
+	msg, _ = text.EncodeHeader(Header{Sequence: 3, Response: true}, []byte("ok"))
+	assert.Equal(t, "#3 ok", string(msg))
+	header, _, _ = text.DecodeHeader(msg)
+	assert.Equal(t, Header{Sequence: 3, Response: true}, header)
+}
@@ -1,73 +1,466 @@
 package linker
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	uuid "github.com/satori/go.uuid"
-	"linker/pkg/bytes"
+	"golang.org/x/sys/unix"
+	"io"
+	"linker/pkg/buffer"
 	"linker/pkg/poller"
+	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrConnClosed     = errors.New("conn: connection closed")
+	ErrWriteQueueFull = errors.New("conn: write queue exceeds high water mark")
+	ErrWriteTimeout   = errors.New("conn: write queue wait timeout")
 )
 
 type Conn interface {
 	ID() string
 	FD() int
 	Close()
-	Push(msg []byte)
+	// Push 将消息放入发送队列，连接已关闭或者发送队列超出高水位时返回错误
+	Push(msg []byte) error
+	// Request 以新的序列号发送请求并等待客户端的响应，响应不会进入处理链。
+	// 需要Codec实现HeaderCodec并带有序列号，ctx结束或者连接关闭时返回错误
+	Request(ctx context.Context, msg []byte) ([]byte, error)
+}
 
+// pollConn 注册到epoll的流式连接，由SubReactor负责读取
+type pollConn interface {
+	Conn
 	read() ([]byte, error)
+	flush() error
+	buffered() bool
 }
 type Connection struct {
-	mu             sync.Mutex
+	mu             sync.Mutex // 保护发送队列
 	instance       net.Conn
 	fd             int
-	scanner        *bufio.Scanner
-	uuid           string // 唯一ID
+	poll           poller.Poller
+	options        *options
+	codec          Codec
+	inbound        *buffer.RingBuffer // 未解码的数据
+	outbound       buffer.Buffer      // 未发送完的数据
+	writable       chan struct{}      // 发送队列减少时关闭，唤醒等待中的Push
+	discarding     int                // 超长帧待丢弃的字节数，-1表示丢弃至下一个帧边界
+	uuid           string             // 唯一ID
 	once           *sync.Once
+	owner          Conn // 实际注册到reactor的连接
 	closedCallback ConnEvent
-	buffer         []byte
+	rmu            sync.Mutex // 避免fd关闭后被复用时读取到其他连接的数据
+	closed         bool
+	readAt         int64 // 最近一次读取到数据的时间(纳秒)
+	writeAt        int64 // 最近一次发送数据的时间(纳秒)
+	requests       requests
 }
 
 func (conn *Connection) FD() int {
 	return conn.fd
 }
 
-func (conn *Connection) Push(msg []byte) {
-	conn.instance.Write(msg)
+// Push 经过编解码器封帧后发送
+func (conn *Connection) Push(msg []byte) error {
+	frame, err := conn.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return conn.write(frame)
 }
 
-func newConn(conn net.Conn) *Connection {
+func newConn(conn net.Conn, opts *options, poll poller.Poller) *Connection {
 	c := &Connection{instance: conn,
 		uuid:    uuid.NewV4().String(),
 		fd:      poller.SocketFD(conn),
+		poll:    poll,
 		once:    new(sync.Once),
-		scanner: bufio.NewScanner(conn),
-		buffer:  bytes.Get(512),
+		options: opts,
+		codec:   opts.codec,
+		inbound: buffer.NewRingBuffer(512),
 	}
-	c.scanner.Buffer(c.buffer, len(c.buffer))
+	c.owner = c
+	c.requests.codec, _ = opts.codec.(HeaderCodec)
 	return c
 }
 
+func (conn *Connection) Request(ctx context.Context, msg []byte) ([]byte, error) {
+	return conn.requests.do(ctx, conn.owner, msg)
+}
+
+func (conn *Connection) resolve(sequence uint32, body []byte) bool {
+	return conn.requests.resolve(sequence, body)
+}
+
 // UUID 返回连接的唯一ID
 func (conn *Connection) ID() string {
 	return conn.uuid
 }
 
+// read 非阻塞地读取并解码一个帧，socket中暂时没有数据时返回nil
 func (conn *Connection) read() ([]byte, error) {
-	if !conn.scanner.Scan() {
-		return nil, conn.scanner.Err()
+	return conn.readFrame(conn.decode)
+}
+
+// readFrame 将socket中的数据读入缓冲区，直至decode解码出一个帧或者socket返回EAGAIN
+func (conn *Connection) readFrame(decode func() ([]byte, error)) ([]byte, error) {
+	for {
+		frame, err := decode()
+		if err != nil || frame != nil {
+			return frame, err
+		}
+
+		n, err := conn.fill()
+		if err == syscall.EAGAIN {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, io.EOF
+		}
+	}
+}
+
+// fill 从socket中读取数据到缓冲区
+func (conn *Connection) fill() (n int, err error) {
+	conn.rmu.Lock()
+	defer conn.rmu.Unlock()
+	if conn.closed {
+		return 0, io.EOF
+	}
+	for {
+		n, err = conn.inbound.CopyFromSocket(conn.fd)
+		if err != syscall.EINTR {
+			if n > 0 {
+				atomic.StoreInt64(&conn.readAt, time.Now().UnixNano())
+			}
+			return
+		}
+	}
+}
+
+// decode 从缓冲区中解码一个帧，超过最大长度的帧按照策略处理
+func (conn *Connection) decode() ([]byte, error) {
+	limit := conn.options.maxFrameSize
+	for {
+		if conn.discarding != 0 && !conn.skip() {
+			return nil, nil
+		}
+
+		length := -1
+		if decoder, ok := conn.codec.(FrameLengthDecoder); ok {
+			var err error
+			if length, err = decoder.FrameLength(conn.inbound); err != nil {
+				if err = conn.decodeError(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if length <= limit {
+			frame, err := conn.codec.Decode(conn.inbound)
+			if err != nil {
+				if err = conn.decodeError(err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if frame != nil {
+				return frame, nil
+			}
+			if conn.inbound.Buffered() < limit {
+				return nil, nil
+			}
+			// 缓冲区已满但仍然无法解码，帧的长度未知
+			length = -1
+		}
+
+		if err := conn.oversize(&FrameTooLargeError{Length: length, Limit: limit}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeError 编解码器自身限制的超长帧同样按WithOversizePolicy处理，其他错误直接返回
+func (conn *Connection) decodeError(err error) error {
+	var tooLarge *FrameTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return err
+	}
+	return conn.oversize(tooLarge)
+}
+
+// oversize 处理超长帧，返回错误时连接将被关闭
+func (conn *Connection) oversize(err *FrameTooLargeError) error {
+	switch conn.options.oversizePolicy {
+	case OversizeSkip:
+	case OversizeCallback:
+		if conn.options.oversizeCallback != nil {
+			conn.options.oversizeCallback(conn, err)
+		}
+	default:
+		return err
+	}
+
+	log.Printf("conn(%s) skip frame: %v", conn.uuid, err)
+	if err.Length < 0 {
+		conn.inbound.Reset()
+		conn.discarding = -1
+	} else {
+		conn.discarding = err.Length
+	}
+	return nil
+}
+
+// skip 丢弃超长帧剩余的数据，返回该帧是否已经丢弃完毕
+func (conn *Connection) skip() bool {
+	if conn.discarding > 0 {
+		n, _ := conn.inbound.Discard(conn.discarding)
+		conn.discarding -= n
+		return conn.discarding == 0
+	}
+
+	// 帧的长度未知，解码出的下一个帧即为超长帧的剩余部分
+	frame, err := conn.codec.Decode(conn.inbound)
+	if err == nil && frame != nil {
+		conn.discarding = 0
+		return true
+	}
+	if conn.inbound.Buffered() >= conn.options.maxFrameSize {
+		conn.inbound.Reset()
+	}
+	return false
+}
+
+// pushShared 广播时多个连接共享同一个编码后的帧
+func (conn *Connection) pushShared(msg *sharedMessage) error {
+	frame, err := msg.encode(conn.codec)
+	if err != nil {
+		return err
+	}
+	return conn.enqueue(frame, true)
+}
+
+func (conn *Connection) write(frame []byte) error {
+	return conn.enqueue(frame, false)
+}
+
+// tryWrite 与write相同，但发送队列超出高水位时直接丢弃frame，不按慢消费者策略阻塞或断开，
+// 用于在读协程中发送控制帧
+func (conn *Connection) tryWrite(frame []byte) error {
+	return conn.appendOutbound(frame, false, conn.checkWritable)
+}
+
+// enqueue 发送队列为空时直接写入socket，未写完的部分放入发送队列，等待可写事件时再发送。
+// shared为true时frame会被多个连接引用，放入发送队列时不拷贝
+func (conn *Connection) enqueue(frame []byte, shared bool) error {
+	return conn.appendOutbound(frame, shared, conn.waitWritable)
+}
+
+// appendOutbound wait在frame放入发送队列前检查高水位，调用时持有锁
+func (conn *Connection) appendOutbound(frame []byte, shared bool, wait func(n int) error) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed {
+		return ErrConnClosed
+	}
+
+	if conn.outbound.IsEmpty() {
+		n, err := conn.writeSocket(frame)
+		if err != nil {
+			return err
+		}
+		if n == len(frame) {
+			return nil
+		}
+		frame = frame[n:]
+	}
+
+	if err := wait(len(frame)); err != nil {
+		return err
+	}
+
+	empty := conn.outbound.IsEmpty()
+	if shared {
+		conn.outbound.PushBackShared(frame)
+	} else {
+		conn.outbound.PushBack(frame)
+	}
+	if empty {
+		return conn.poll.EnableWrite(conn.fd)
+	}
+	return nil
+}
+
+// checkWritable 发送队列超出高水位时返回ErrWriteQueueFull，调用时需持有锁
+func (conn *Connection) checkWritable(n int) error {
+	if conn.outbound.Buffered()+n <= conn.options.writeHighWaterMark || conn.outbound.IsEmpty() {
+		return nil
+	}
+	return ErrWriteQueueFull
+}
+
+// waitWritable 发送队列超出高水位时按照慢消费者策略处理，调用时需持有锁
+func (conn *Connection) waitWritable(n int) error {
+	highWater := conn.options.writeHighWaterMark
+	if conn.outbound.Buffered()+n <= highWater || conn.outbound.IsEmpty() {
+		return nil
+	}
+
+	switch conn.options.slowConsumerPolicy {
+	case SlowConsumerBlock:
+	case SlowConsumerDisconnect:
+		go conn.owner.Close()
+		return ErrWriteQueueFull
+	default:
+		return ErrWriteQueueFull
+	}
+
+	timer := time.NewTimer(conn.options.writeTimeout)
+	defer timer.Stop()
+	for conn.outbound.Buffered()+n > highWater && !conn.outbound.IsEmpty() {
+		if conn.writable == nil {
+			conn.writable = make(chan struct{})
+		}
+		writable := conn.writable
+		conn.mu.Unlock()
+		select {
+		case <-writable:
+			conn.mu.Lock()
+		case <-timer.C:
+			conn.mu.Lock()
+			return ErrWriteTimeout
+		}
+		if conn.closed {
+			return ErrConnClosed
+		}
 	}
+	return nil
+}
 
-	return conn.scanner.Bytes(), nil
+// buffered 发送队列中是否还有未发送的数据
+func (conn *Connection) buffered() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return !conn.closed && !conn.outbound.IsEmpty()
+}
+
+// flush 在可写事件中通过writev批量发送队列中的数据，全部发送完成后取消关注可写事件
+func (conn *Connection) flush() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed {
+		return nil
+	}
+
+	for !conn.outbound.IsEmpty() {
+		bs := conn.outbound.Peek(maxWritevBytes)
+		if len(bs) > maxWritevIovecs {
+			bs = bs[:maxWritevIovecs]
+		}
+		n, err := conn.writevSocket(bs)
+		_, _ = conn.outbound.Discard(n)
+		if n > 0 {
+			conn.notifyWritable()
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 { // socket缓冲区已满，等待下一次可写事件
+			return nil
+		}
+	}
+	return conn.poll.DisableWrite(conn.fd)
+}
+
+const (
+	maxWritevBytes  = 1 << 20
+	maxWritevIovecs = 1024 // IOV_MAX
+)
+
+// writeSocket 非阻塞地写入socket，缓冲区已满时返回0
+func (conn *Connection) writeSocket(p []byte) (int, error) {
+	for {
+		n, err := syscall.Write(conn.fd, p)
+		switch err {
+		case nil:
+			atomic.StoreInt64(&conn.writeAt, time.Now().UnixNano())
+			return n, nil
+		case syscall.EINTR:
+			continue
+		case syscall.EAGAIN:
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+}
+
+// writevSocket 非阻塞地将多段数据通过一次系统调用写入socket，缓冲区已满时返回0
+func (conn *Connection) writevSocket(bs [][]byte) (int, error) {
+	if len(bs) == 1 {
+		return conn.writeSocket(bs[0])
+	}
+	for {
+		n, err := unix.Writev(conn.fd, bs)
+		switch err {
+		case nil:
+			atomic.StoreInt64(&conn.writeAt, time.Now().UnixNano())
+			return n, nil
+		case unix.EINTR:
+			continue
+		case unix.EAGAIN:
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+}
+
+// activity 返回最近一次读取和发送数据的时间
+func (conn *Connection) activity() (readAt, writeAt int64) {
+	return atomic.LoadInt64(&conn.readAt), atomic.LoadInt64(&conn.writeAt)
+}
+
+// ping 经过编解码器发送心跳
+func (conn *Connection) ping(msg []byte) error {
+	return conn.Push(msg)
+}
+
+func (conn *Connection) notifyWritable() {
+	if conn.writable != nil {
+		close(conn.writable)
+		conn.writable = nil
+	}
 }
 
 func (conn *Connection) Close() {
 	conn.once.Do(func() {
-		bytes.Put(conn.buffer)
-		if conn.closedCallback != nil {
-			conn.closedCallback(conn)
-		}
-		_ = conn.instance.Close()
+		conn.release()
 	})
 
 }
+
+// release 释放连接资源
+func (conn *Connection) release() {
+	if conn.closedCallback != nil {
+		conn.closedCallback(conn.owner)
+	}
+	conn.rmu.Lock()
+	conn.mu.Lock()
+	conn.closed = true
+	_ = conn.instance.Close()
+	conn.outbound.Reset()
+	conn.notifyWritable()
+	conn.mu.Unlock()
+	conn.rmu.Unlock()
+	conn.requests.close()
+}
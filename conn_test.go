@@ -0,0 +1,159 @@
+package linker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"linker/pkg/buffer"
+	"linker/pkg/poller"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestConn(codec Codec, opts ...Option) *Connection {
+	options := defaultOption()
+	options.codec = codec
+	for _, setter := range opts {
+		setter(options)
+	}
+	return &Connection{options: options, codec: codec, inbound: buffer.NewRingBuffer(16)}
+}
+
+func TestOversizeClose(t *testing.T) {
+	conn := newTestConn(NewLineCodec(), WithMaxFrameSize(8))
+	_, _ = conn.inbound.Write([]byte("hello,world"))
+
+	_, err := conn.decode()
+	assert.Equal(t, &FrameTooLargeError{Length: -1, Limit: 8}, err)
+}
+
+func TestOversizeSkipDelimiter(t *testing.T) {
+	conn := newTestConn(NewLineCodec(), WithMaxFrameSize(8), WithOversizePolicy(OversizeSkip))
+	_, _ = conn.inbound.Write([]byte("hello,world"))
+
+	frame, err := conn.decode()
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+
+	_, _ = conn.inbound.Write([]byte("!!\nhi\n"))
+	frame, err = conn.decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "hi", string(frame))
+}
+
+func TestOversizeCallbackLengthField(t *testing.T) {
+	var called error
+	codec := NewLengthPrefixCodec()
+	conn := newTestConn(codec, WithMaxFrameSize(8), WithOversizeCallback(func(conn Conn, err error) {
+		called = err
+	}))
+
+	large, _ := codec.Encode([]byte("hello,world"))
+	small, _ := codec.Encode([]byte("hi"))
+	_, _ = conn.inbound.Write(large[:6])
+	frame, err := conn.decode()
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+	assert.Equal(t, &FrameTooLargeError{Length: 15, Limit: 8}, called)
+
+	_, _ = conn.inbound.Write(large[6:])
+	_, _ = conn.inbound.Write(small)
+	frame, err = conn.decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "hi", string(frame))
+}
+
+func TestOversizeSkipCodecLimit(t *testing.T) {
+	// 编解码器自身的长度限制同样按WithOversizePolicy处理
+	codec := NewLengthPrefixCodec().WithMaxFrameLength(8)
+	conn := newTestConn(codec, WithOversizePolicy(OversizeSkip))
+
+	large := append([]byte{0, 0, 0, 11}, "hello,world"...)
+	small, _ := codec.Encode([]byte("hi"))
+	_, _ = conn.inbound.Write(large[:6])
+	frame, err := conn.decode()
+	assert.Nil(t, err)
+	assert.Nil(t, frame)
+
+	_, _ = conn.inbound.Write(large[6:])
+	_, _ = conn.inbound.Write(small)
+	frame, err = conn.decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "hi", string(frame))
+}
+
+// socketPair 创建一对本地TCP连接
+func socketPair(t *testing.T) (server net.Conn, client net.Conn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer lis.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, _ := lis.Accept()
+		accepted <- conn
+	}()
+	client, err = net.Dial("tcp", lis.Addr().String())
+	assert.Nil(t, err)
+	server = <-accepted
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return
+}
+
+func TestWriteQueue(t *testing.T) {
+	server, client := socketPair(t)
+	poll, err := poller.CreateEpoll()
+	assert.Nil(t, err)
+	options := defaultOption()
+	options.codec = NewFixedLengthCodec(1024)
+	options.writeHighWaterMark = 4096
+	conn := newConn(server, options, poll)
+	assert.Nil(t, poll.Add(conn.FD()))
+
+	// 客户端不读取数据，直至发送队列超出高水位
+	var pushed int
+	for err == nil {
+		if err = conn.Push([]byte("hello")); err == nil {
+			pushed++
+		}
+	}
+	assert.Equal(t, ErrWriteQueueFull, err)
+	assert.False(t, conn.outbound.IsEmpty())
+
+	received := make(chan int)
+	go func() {
+		n, _ := io.ReadFull(client, make([]byte, pushed*1024))
+		received <- n
+	}()
+	for !conn.outbound.IsEmpty() {
+		_, write, _ := poll.Wait()
+		if len(write) > 0 {
+			assert.Nil(t, conn.flush())
+		}
+	}
+	assert.Equal(t, pushed*1024, <-received)
+
+	conn.Close()
+	assert.Equal(t, ErrConnClosed, conn.Push([]byte("hello")))
+}
+
+func TestWriteQueueBlock(t *testing.T) {
+	server, _ := socketPair(t)
+	poll, err := poller.CreateEpoll()
+	assert.Nil(t, err)
+	options := defaultOption()
+	options.codec = NewFixedLengthCodec(1024)
+	options.writeHighWaterMark = 4096
+	options.slowConsumerPolicy = SlowConsumerBlock
+	options.writeTimeout = 10 * time.Millisecond
+	conn := newConn(server, options, poll)
+	assert.Nil(t, poll.Add(conn.FD()))
+
+	for err == nil {
+		err = conn.Push([]byte("hello"))
+	}
+	assert.Equal(t, ErrWriteTimeout, err)
+}
@@ -2,16 +2,20 @@ package linker
 
 import (
 	"context"
-	"log"
 	"math"
 )
 
 type Context struct {
 	context.Context
-	engine *Engine
-	conn   Conn
-	body   []byte
-	index  int8
+	engine   *Engine
+	conn     Conn
+	body     []byte
+	header   Header
+	handlers []HandleFunc // 当前消息的处理链，随Context复用
+	index    int8
+	handler  int8 // 路由的处理函数在处理链中的位置，没有匹配的路由时为-1
+	handled  bool // 路由的处理函数是否已执行
+	errors   []error
 }
 
 func (ctx *Context) Conn() Conn {
@@ -21,22 +25,71 @@ func (ctx *Context) Conn() Conn {
 func (ctx *Context) Body() []byte {
 	return ctx.body
 }
+
+// Route 返回消息的路由键，Codec没有实现HeaderCodec时为零值
+func (ctx *Context) Route() Route {
+	return ctx.header.Route
+}
+
+// Sequence 返回消息的序列号，Codec没有实现HeaderCodec时为0
+func (ctx *Context) Sequence() uint32 {
+	return ctx.header.Sequence
+}
+
+// Reply 发送带有相同路由和序列号的响应，客户端据此匹配并发请求的响应。
+// Context在处理结束后会被复用，需要在handler返回前调用
+func (ctx *Context) Reply(msg []byte) error {
+	if codec := ctx.engine.headerCodec; codec != nil {
+		var err error
+		header := ctx.header
+		header.Response = true
+		if msg, err = codec.EncodeHeader(header, msg); err != nil {
+			return err
+		}
+	}
+	return ctx.conn.Push(msg)
+}
+
 func (ctx *Context) Run() {
+	defer ctx.engine.releaseContext(ctx)
+	defer ctx.engine.recoverPanic(ctx)
 	ctx.engine.processContext(ctx)
-	ctx.engine.releaseContext(ctx)
 }
 
+// Next 依次执行处理链中剩余的handler，全部执行完或者被终止后返回
 func (ctx *Context) Next() {
-	if ctx.index < maxIndex {
+	ctx.index++
+	for ctx.index < int8(len(ctx.handlers)) {
+		if ctx.index == ctx.handler {
+			ctx.handled = true
+		}
+		ctx.handlers[ctx.index](ctx)
 		ctx.index++
-		ctx.engine.handleChains[ctx.index](ctx)
 	}
 }
+
+// Abort 终止处理链，当前handler返回后不再执行后续的handler
 func (ctx *Context) Abort() {
-	ctx.index = maxIndex
-	log.Println("已被终止...")
+	ctx.index = abortIndex
+}
+
+// AbortWithError 记录错误并终止处理链
+func (ctx *Context) AbortWithError(err error) {
+	ctx.errors = append(ctx.errors, err)
+	ctx.Abort()
+}
+
+// IsAborted 处理链是否已被终止
+func (ctx *Context) IsAborted() bool {
+	return ctx.index >= abortIndex
+}
+
+// Errors 返回通过AbortWithError记录的错误
+func (ctx *Context) Errors() []error {
+	return ctx.errors
 }
 
 const (
-	maxIndex = math.MaxInt8 / 2
+	// abortIndex 处理链最多容纳的handler数量，index达到该值表示已终止
+	abortIndex = math.MaxInt8 / 2
 )
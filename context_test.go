@@ -0,0 +1,108 @@
+package linker
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContextChain(t *testing.T) {
+	engine := newEngine(1)
+	var trace []string
+	engine.Use(func(ctx *Context) {
+		trace = append(trace, "a:before")
+		ctx.Next()
+		trace = append(trace, "a:after")
+	}, func(ctx *Context) {
+		trace = append(trace, "b")
+	})
+	engine.NotFound(func(ctx *Context) {
+		trace = append(trace, "handler")
+	})
+
+	ctx := &Context{engine: engine, body: []byte("ping")}
+	engine.processContext(ctx)
+	assert.Equal(t, []string{"a:before", "b", "handler", "a:after"}, trace)
+	assert.False(t, ctx.IsAborted())
+}
+
+func TestContextAbort(t *testing.T) {
+	engine := newEngine(1)
+	errDenied := errors.New("denied")
+	handled := false
+	engine.Use(func(ctx *Context) {
+		ctx.AbortWithError(errDenied)
+		// Abort之后再调用Next也不会继续执行
+		ctx.Next()
+	})
+	engine.NotFound(func(ctx *Context) {
+		handled = true
+	})
+
+	var aborted []error
+	engine.OnAbort(func(ctx *Context) {
+		aborted = append(aborted, ctx.Errors()...)
+	})
+
+	ctx := &Context{engine: engine}
+	engine.processContext(ctx)
+	assert.False(t, handled)
+	assert.True(t, ctx.IsAborted())
+	assert.Equal(t, []error{errDenied}, ctx.Errors())
+	assert.Equal(t, []error{errDenied}, aborted)
+
+	// 处理函数自己终止时不会触发OnAbort
+	aborted = nil
+	engine.handleChains = nil
+	engine.NotFound(func(ctx *Context) {
+		ctx.AbortWithError(errDenied)
+	})
+	ctx = &Context{engine: engine}
+	engine.processContext(ctx)
+	assert.True(t, ctx.IsAborted())
+	assert.Nil(t, aborted)
+}
+
+func TestContextGroup(t *testing.T) {
+	engine := newEngine(1)
+	var trace []string
+	engine.Use(func(ctx *Context) {
+		trace = append(trace, "global")
+	})
+	engine.Group(func(ctx *Context) bool {
+		return string(ctx.Body()) == "admin"
+	}, func(ctx *Context) {
+		trace = append(trace, "auth")
+	}).Use(func(ctx *Context) {
+		trace = append(trace, "audit")
+	})
+	engine.NotFound(func(ctx *Context) {
+		trace = append(trace, "handler:"+string(ctx.Body()))
+	})
+
+	engine.processContext(&Context{engine: engine, body: []byte("echo")})
+	assert.Equal(t, []string{"global", "handler:echo"}, trace)
+
+	trace = nil
+	engine.processContext(&Context{engine: engine, body: []byte("admin")})
+	assert.Equal(t, []string{"global", "auth", "audit", "handler:admin"}, trace)
+}
+
+func TestChainLimit(t *testing.T) {
+	engine := newEngine(1)
+	handlers := make([]HandleFunc, abortIndex/2+1)
+	for i := range handlers {
+		handlers[i] = func(ctx *Context) {}
+	}
+	engine.Use(handlers...)
+	engine.Handle(1, handlers[:abortIndex/2-1]...)
+	// 超出限制时在注册时panic，而不是在处理消息时
+	assert.Panics(t, func() {
+		engine.Handle(2, handlers...)
+	})
+	// 超出限制的路由没有被注册
+	assert.Equal(t, abortIndex/2-1, engine.Router.longest())
+	assert.Panics(t, func() {
+		engine.Group(MatchCommand(1), handlers[0])
+	})
+}
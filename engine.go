@@ -2,38 +2,135 @@ package linker
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
 )
 
 type HandleFunc func(ctx *Context)
 
+// PanicError 处理请求时发生的panic
+type PanicError struct {
+	Value interface{} // recover()的返回值
+	Stack []byte      // panic时的调用栈
+	Body  []byte      // 正在处理的消息
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("linker: panic in handler: %v", err.Value)
+}
+
 type Engine struct {
-	handleChains []HandleFunc
+	*Router
+	handleChains []HandleFunc // 全局中间件
+	groups       []*Group     // 按消息路由生效的中间件
+	headerCodec  HeaderCodec  // 解析帧头中的路由和序列号，为nil时所有消息交给NotFound
 	contextPools []*sync.Pool
 	mask         int
+	panicHandler func(ctx *Context, err *PanicError)
+	aborted      []HandleFunc // 处理链在执行到路由的处理函数之前被终止时调用
 }
 
 func newEngine(ctxPoolSize int) *Engine {
 	engine := &Engine{
-		mask: ctxPoolSize,
+		Router: newRouter(),
+		mask:   ctxPoolSize,
 	}
+	engine.Router.check = engine.checkChain
 	engine.init()
 	return engine
 }
 
 func (e *Engine) processContext(ctx *Context) {
-	e.handleChains[0](ctx)
+	e.decodeHeader(ctx)
+	if ctx.header.Response {
+		// 客户端对服务端请求的响应，交给等待中的Request，不进入处理链
+		if r, ok := ctx.conn.(responder); ok {
+			r.resolve(ctx.header.Sequence, ctx.body)
+		}
+		return
+	}
+	ctx.handlers = e.buildChain(ctx, ctx.handlers[:0])
+	ctx.index = -1
+	ctx.handler, ctx.handled = -1, false
+	if len(e.Router.match(ctx.header.Route)) > 0 {
+		ctx.handler = int8(len(ctx.handlers)) - 1
+	}
+	ctx.Next()
+	if ctx.IsAborted() && !ctx.handled {
+		for _, handler := range e.aborted {
+			handler(ctx)
+		}
+	}
+}
+
+// decodeHeader 解析消息的帧头并去掉帧头，解析失败的消息交给NotFound处理
+func (e *Engine) decodeHeader(ctx *Context) {
+	ctx.header = Header{}
+	if e.headerCodec == nil {
+		return
+	}
+	header, body, err := e.headerCodec.DecodeHeader(ctx.body)
+	if err != nil {
+		ctx.errors = append(ctx.errors, err)
+		return
+	}
+	ctx.header, ctx.body = header, body
+}
+
+// buildChain 按全局中间件、匹配的分组中间件、路由处理链的顺序组装处理链
+func (e *Engine) buildChain(ctx *Context, chain []HandleFunc) []HandleFunc {
+	chain = append(chain, e.handleChains...)
+	for _, group := range e.groups {
+		if group.match(ctx) {
+			chain = append(chain, group.handlers...)
+		}
+	}
+	chain = append(chain, e.Router.match(ctx.header.Route)...)
+	return chain
 }
 
 func (e *Engine) allocateContext(conn Conn, body []byte) *Context {
 	return &Context{engine: e, conn: conn, body: body}
 }
 
+// Use 注册全局中间件，按注册顺序执行，需要在启动前调用
 func (e *Engine) Use(handler ...HandleFunc) {
+	e.checkChain(len(handler), 0)
 	e.handleChains = append(e.handleChains, handler...)
 }
 
+// checkChain 在注册前检查加入middleware个中间件或者长度为route的路由后最长的处理链，
+// 避免在处理消息时才发现超出abortIndex
+func (e *Engine) checkChain(middleware, route int) {
+	n := len(e.handleChains) + middleware + maxInt(e.Router.longest(), route)
+	for _, group := range e.groups {
+		n += len(group.handlers)
+	}
+	if n >= abortIndex {
+		panic(fmt.Sprintf("linker: too many handlers, the longest chain has %d, limit %d", n, abortIndex-1))
+	}
+}
+
+// OnAbort 注册处理链在执行到路由的处理函数之前被终止时调用的函数，没有匹配的路由时同样生效，
+// 与中间件的注册顺序无关，可以通过ctx.Errors()获取终止原因，需要在启动前调用
+func (e *Engine) OnAbort(handlers ...HandleFunc) {
+	e.aborted = append(e.aborted, handlers...)
+}
+
+// OnRequest 等同于NotFound，没有注册路由时处理所有消息
+func (e *Engine) OnRequest(request HandleFunc) {
+	e.NotFound(request)
+}
+
+// Group 创建只对match返回true的消息生效的中间件分组，需要在启动前调用
+func (e *Engine) Group(match func(ctx *Context) bool, handlers ...HandleFunc) *Group {
+	e.checkChain(len(handlers), 0)
+	group := &Group{engine: e, match: match, handlers: handlers}
+	e.groups = append(e.groups, group)
+	return group
+}
+
 func (e *Engine) init() {
 	for i := 0; i < e.mask; i++ {
 		e.contextPools = append(e.contextPools, &sync.Pool{New: func() interface{} {
@@ -42,22 +139,36 @@ func (e *Engine) init() {
 	}
 }
 
-func (e *Engine) buildContext(conn Conn) (*Context, error) {
+// buildContext 读取一个帧并创建上下文，没有完整的帧时返回nil
+func (e *Engine) buildContext(conn pollConn) (*Context, error) {
 	body, err := conn.read()
-	if err != nil {
+	if err != nil || body == nil {
 		return nil, err
 	}
 
-	if len(body) == 0 {
-		return nil, errors.New("empty data")
-	}
+	return e.newContext(conn, body), nil
+}
 
+func (e *Engine) newContext(conn Conn, body []byte) *Context {
 	ctx := e.withPool(conn.FD()).Get().(*Context)
 	ctx.body = body
-	ctx.index = 0
+	ctx.index = -1
+	ctx.errors = ctx.errors[:0]
 	ctx.conn = conn
 	ctx.Context = context.Background()
-	return ctx, nil
+	return ctx
+}
+
+// recoverPanic 恢复处理请求时的panic，只影响当前连接
+func (e *Engine) recoverPanic(ctx *Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := &PanicError{Value: r, Stack: debug.Stack(), Body: ctx.body}
+	if e.panicHandler != nil {
+		e.panicHandler(ctx, err)
+	}
 }
 
 func (e *Engine) releaseContext(ctx *Context) {
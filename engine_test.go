@@ -0,0 +1,91 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandlerPanic(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2), WithCloseOnPanic(true))
+	panicked := make(chan *PanicError, 1)
+	reactor.OnPanic(func(conn Conn, err *PanicError) {
+		panicked <- err
+	})
+	reactor.OnRequest(func(ctx *Context) {
+		if string(ctx.Body()) == "boom" {
+			panic("malformed packet")
+		}
+		_ = ctx.Conn().Push(ctx.Body())
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	bad, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer bad.Close()
+	good, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer good.Close()
+
+	_, _ = bad.Write([]byte("boom\n"))
+	select {
+	case err := <-panicked:
+		assert.Equal(t, "malformed packet", err.Value)
+		assert.Equal(t, "boom", string(err.Body))
+		assert.Contains(t, string(err.Stack), "TestHandlerPanic")
+	case <-time.After(time.Second):
+		t.Fatal("panic not reported")
+	}
+
+	// 只关闭出错的连接
+	_ = bad.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = bufio.NewReader(bad).ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	_, _ = good.Write([]byte("hello\n"))
+	_ = good.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(good).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", line)
+}
+
+func TestEventPanic(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	panicked := make(chan interface{}, 2)
+	reactor.OnPanic(func(conn Conn, err *PanicError) {
+		panicked <- err.Value
+	})
+	reactor.OnConnect(func(conn Conn) {
+		panic("connect")
+	})
+	reactor.OnDisconnect(func(conn Conn) {
+		panic("disconnect")
+	})
+	reactor.OnRequest(func(ctx *Context) {
+		_ = ctx.Conn().Push(ctx.Body())
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	conn, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	_, _ = conn.Write([]byte("hello\n"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", line)
+	_ = conn.Close()
+
+	for _, value := range []string{"connect", "disconnect"} {
+		select {
+		case v := <-panicked:
+			assert.Equal(t, value, v)
+		case <-time.After(time.Second):
+			t.Fatalf("%s panic not reported", value)
+		}
+	}
+}
@@ -1,11 +1,21 @@
 package linker
 
+import (
+	"log"
+	"runtime/debug"
+)
+
 type ConnEvent func(conn Conn)
 
+type IdleEvent func(conn Conn, state IdleState)
+
+type PanicEvent func(conn Conn, err *PanicError)
+
 type EventHandler struct {
 	connect    ConnEvent
 	disconnect ConnEvent
-	request    HandleFunc
+	idle       IdleEvent
+	panicked   PanicEvent
 }
 
 func (handler *EventHandler) OnConnect(connect ConnEvent) {
@@ -16,22 +26,19 @@ func (handler *EventHandler) OnDisconnect(disconnect ConnEvent) {
 	handler.disconnect = disconnect
 }
 
-func (handler *EventHandler) OnRequest(request HandleFunc) {
-	handler.request = request
+func (handler *EventHandler) OnIdle(idle IdleEvent) {
+	handler.idle = idle
 }
 
-func (handler EventHandler) HandleRequest(ctx *Context) {
-	if handler.request == nil {
-		return
-	}
-	handler.request(ctx)
+func (handler *EventHandler) OnPanic(panicked PanicEvent) {
+	handler.panicked = panicked
 }
 
 func (handler EventHandler) HandleConnect(conn Conn) {
 	if handler.connect == nil {
 		return
 	}
-
+	defer handler.recoverEvent(conn)
 	handler.connect(conn)
 }
 
@@ -39,5 +46,30 @@ func (handler EventHandler) HandleDisconnect(conn Conn) {
 	if handler.disconnect == nil {
 		return
 	}
+	defer handler.recoverEvent(conn)
 	handler.disconnect(conn)
 }
+
+func (handler EventHandler) HandleIdle(conn Conn, state IdleState) {
+	if handler.idle == nil {
+		return
+	}
+	defer handler.recoverEvent(conn)
+	handler.idle(conn, state)
+}
+
+// recoverEvent 恢复连接事件回调中的panic并通过OnPanic报告，避免影响accept和读取协程
+func (handler EventHandler) recoverEvent(conn Conn) {
+	if r := recover(); r != nil {
+		handler.HandlePanic(conn, &PanicError{Value: r, Stack: debug.Stack()})
+	}
+}
+
+// HandlePanic 没有设置OnPanic时打印调用栈
+func (handler EventHandler) HandlePanic(conn Conn, err *PanicError) {
+	if handler.panicked == nil {
+		log.Printf("conn(%s) %v\n%s", conn.ID(), err, err.Stack)
+		return
+	}
+	handler.panicked(conn, err)
+}
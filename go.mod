@@ -1,6 +1,6 @@
 module linker
 
-go 1.17
+go 1.18
 
 require (
 	github.com/bytedance/gopkg v0.0.0-20220623074550-9d6d3df70991 // indirect
@@ -0,0 +1,15 @@
+package linker
+
+// Group 一组只对部分消息生效的中间件，在全局中间件之后、请求处理函数之前执行
+type Group struct {
+	engine   *Engine
+	match    func(ctx *Context) bool
+	handlers []HandleFunc
+}
+
+// Use 向分组追加中间件
+func (group *Group) Use(handlers ...HandleFunc) *Group {
+	group.engine.checkChain(len(handlers), 0)
+	group.handlers = append(group.handlers, handlers...)
+	return group
+}
@@ -0,0 +1,26 @@
+package linker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// startReactor 在随机端口上启动reactor，返回监听地址和关闭reactor的函数
+func startReactor(t *testing.T, reactor EventLoop, protocol string) (string, func()) {
+	if err := reactor.Start(protocol, "127.0.0.1:0"); err != nil {
+		t.Fatalf("reactor.Start(%s) error(%v)", protocol, err)
+	}
+	return reactor.Addr().String(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = reactor.Shutdown(ctx)
+	}
+}
+
+// waitFor 等待cond成立，最多等待一秒
+func waitFor(cond func() bool) {
+	for i := 0; i < 100 && !cond(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,184 @@
+package linker
+
+import (
+	"linker/pkg/queue"
+	"time"
+)
+
+// IdleState 空闲事件的类型
+type IdleState int
+
+const (
+	IdleRead  IdleState = iota // 超过读空闲时间未收到数据
+	IdleWrite                  // 超过写空闲时间未发送数据
+	IdleAll                    // 超过空闲时间既未收到也未发送数据
+)
+
+func (state IdleState) String() string {
+	switch state {
+	case IdleRead:
+		return "read idle"
+	case IdleWrite:
+		return "write idle"
+	default:
+		return "all idle"
+	}
+}
+
+// idleConn 支持空闲检测的连接
+type idleConn interface {
+	Conn
+	// activity 返回最近一次读取和发送数据的时间(纳秒)
+	activity() (readAt, writeAt int64)
+	// ping 发送服务端心跳
+	ping(msg []byte) error
+}
+
+// idleEntry 连接在延时队列中的检测任务，每个连接同时只有一个任务，只在检测协程中修改
+type idleEntry struct {
+	conn       idleConn
+	since      int64 // 开始检测的时间
+	readFired  int64 // 上次触发各类事件的时间
+	writeFired int64
+	allFired   int64
+	missed     int // 连续触发IdleRead的次数
+}
+
+// idleChecker 所有连接共用一个延时队列，到期时检查连接的活动时间，
+// 有活动则按最新的活动时间重新入队，避免为每个连接创建定时器
+type idleChecker struct {
+	reactor *MainReactor
+	queue   *queue.DelayQueue[*idleEntry]
+	read    int64
+	write   int64
+	all     int64
+}
+
+// newIdleChecker 没有开启任何空闲检测时返回nil
+func newIdleChecker(reactor *MainReactor) *idleChecker {
+	opts := reactor.options
+	if opts.readIdleTimeout <= 0 && opts.writeIdleTimeout <= 0 && opts.allIdleTimeout <= 0 {
+		return nil
+	}
+	return &idleChecker{
+		reactor: reactor,
+		queue:   queue.NewDelayQueue[*idleEntry](1024),
+		read:    int64(opts.readIdleTimeout),
+		write:   int64(opts.writeIdleTimeout),
+		all:     int64(opts.allIdleTimeout),
+	}
+}
+
+func (checker *idleChecker) add(conn Conn) {
+	c, ok := conn.(idleConn)
+	if !ok {
+		return
+	}
+	now := time.Now().UnixNano()
+	entry := &idleEntry{conn: c, since: now}
+	checker.offer(entry, now+checker.minTimeout())
+}
+
+func (checker *idleChecker) minTimeout() int64 {
+	timeout := int64(0)
+	for _, t := range []int64{checker.read, checker.write, checker.all} {
+		if t > 0 && (timeout == 0 || t < timeout) {
+			timeout = t
+		}
+	}
+	return timeout
+}
+
+// offer 延时队列以毫秒为单位，向上取整避免提前到期
+func (checker *idleChecker) offer(entry *idleEntry, deadline int64) {
+	ms := int64(time.Millisecond)
+	_, _ = checker.queue.Offer(entry, (deadline+ms-1)/ms)
+}
+
+func (checker *idleChecker) run(done chan struct{}) {
+	go checker.queue.Poll(done, func() int64 {
+		return time.Now().UnixMilli()
+	})
+	for {
+		select {
+		case item := <-checker.queue.C:
+			checker.check(item, time.Now().UnixNano())
+		case <-done:
+			return
+		}
+	}
+}
+
+func (checker *idleChecker) check(entry *idleEntry, now int64) {
+	conn := entry.conn
+	// 连接已关闭，不再检测
+	if checker.reactor.registry.get(conn.ID()) != Conn(conn) {
+		return
+	}
+
+	readAt, writeAt := conn.activity()
+	readAt, writeAt = maxInt64(readAt, entry.since), maxInt64(writeAt, entry.since)
+	states := make([]IdleState, 0, 3)
+	next := int64(0)
+	schedule := func(deadline int64) {
+		if next == 0 || deadline < next {
+			next = deadline
+		}
+	}
+
+	if checker.read > 0 {
+		if readAt > entry.readFired {
+			entry.missed = 0
+		}
+		if now-maxInt64(readAt, entry.readFired) >= checker.read {
+			states = append(states, IdleRead)
+			entry.readFired = now
+			entry.missed++
+		}
+		schedule(maxInt64(readAt, entry.readFired) + checker.read)
+	}
+	if checker.write > 0 {
+		if now-maxInt64(writeAt, entry.writeFired) >= checker.write {
+			states = append(states, IdleWrite)
+			entry.writeFired = now
+		}
+		schedule(maxInt64(writeAt, entry.writeFired) + checker.write)
+	}
+	if checker.all > 0 {
+		last := maxInt64(maxInt64(readAt, writeAt), entry.allFired)
+		if now-last >= checker.all {
+			states = append(states, IdleAll)
+			entry.allFired = now
+		}
+		schedule(maxInt64(maxInt64(readAt, writeAt), entry.allFired) + checker.all)
+	}
+
+	opts := checker.reactor.options
+	expired := entry.missed > 0 && opts.maxMissedHeartbeat > 0 && entry.missed >= opts.maxMissedHeartbeat
+	if !expired {
+		checker.offer(entry, next)
+	}
+	if len(states) == 0 {
+		return
+	}
+
+	// 回调和关闭连接可能阻塞，交给worker pool执行
+	checker.reactor.subReactorOf(conn).workerPool.Schedule(func() {
+		for _, state := range states {
+			checker.reactor.HandleIdle(conn, state)
+			if state == IdleRead && opts.heartbeat != nil && !expired {
+				_ = conn.ping(opts.heartbeat)
+			}
+		}
+		if expired {
+			conn.Close()
+		}
+	})
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdleHeartbeat(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2),
+		WithReadIdleTimeout(100*time.Millisecond),
+		WithMaxMissedHeartbeat(2),
+		WithHeartbeat([]byte("ping")))
+	var (
+		mu     sync.Mutex
+		states []IdleState
+	)
+	reactor.OnIdle(func(conn Conn, state IdleState) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	_ = c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(c)
+
+	// 第一次读空闲发送心跳，第二次关闭连接
+	line, err := reader.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "ping\n", line)
+	_, err = reader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	waitFor(func() bool { return reactor.Count() == 0 })
+	assert.Equal(t, 0, reactor.Count())
+	mu.Lock()
+	assert.Equal(t, []IdleState{IdleRead, IdleRead}, states)
+	mu.Unlock()
+}
+
+func TestIdleActivity(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2),
+		WithAllIdleTimeout(150*time.Millisecond),
+		WithMaxMissedHeartbeat(1))
+	idle := make(chan IdleState, 4)
+	reactor.OnIdle(func(conn Conn, state IdleState) {
+		idle <- state
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+
+	// 持续发送数据的连接不会空闲
+	for i := 0; i < 5; i++ {
+		_, _ = c.Write([]byte("hello\n"))
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Len(t, idle, 0)
+
+	select {
+	case state := <-idle:
+		assert.Equal(t, IdleAll, state)
+	case <-time.After(time.Second):
+		t.Fatal("idle event not fired")
+	}
+	// 只开启了IdleAll，不会因为读空闲关闭连接
+	assert.Equal(t, 1, reactor.Count())
+}
@@ -1,6 +1,10 @@
 package linker
 
-import "linker/pkg/utils"
+import (
+	"context"
+	"linker/pkg/utils"
+	"net"
+)
 
 const (
 	TCP = "tcp"
@@ -11,9 +15,58 @@ const (
 type EventLoop interface {
 	OnConnect(connect ConnEvent)
 	OnDisconnect(disconnect ConnEvent)
+	// OnIdle 连接空闲超时时触发，需要通过WithReadIdleTimeout等选项开启
+	OnIdle(idle IdleEvent)
+	// OnPanic 处理请求时发生panic时触发，参数中包括调用栈和正在处理的消息
+	OnPanic(panicked PanicEvent)
+	// OnRequest 处理未匹配任何路由的消息，等同于NotFound
 	OnRequest(request HandleFunc)
+	// Handle 注册命令ID的处理链，最后一个为处理函数，之前的为该路由的中间件
+	Handle(command uint32, handlers ...HandleFunc)
+	// HandleTopic 注册主题的处理链
+	HandleTopic(topic string, handlers ...HandleFunc)
+	// NotFound 注册未匹配任何路由的消息的处理链
+	NotFound(handlers ...HandleFunc)
+	// HasRoute 路由是否已注册
+	HasRoute(route Route) bool
 	Use(handlers ...HandleFunc)
+	// Group 注册只对match返回true的消息生效的中间件
+	Group(match func(ctx *Context) bool, handlers ...HandleFunc) *Group
+	// OnAbort 处理链在执行到路由的处理函数之前被终止时触发，与中间件的注册顺序无关
+	OnAbort(handlers ...HandleFunc)
+	// Run 启动服务并阻塞直至Shutdown完成
 	Run(protocol string, bind string) (err error)
+	// Start 启动服务，不会阻塞
+	Start(protocol string, bind string) (err error)
+	// Shutdown 优雅地关闭服务
+	Shutdown(ctx context.Context) error
+	// Wait 阻塞直至Shutdown完成
+	Wait() error
+	// Addr 返回监听的地址，未启动时返回nil
+	Addr() net.Addr
+
+	// GetConn 根据Conn.ID()查找连接，不存在时返回nil
+	GetConn(id string) Conn
+	// Range 遍历所有连接，fn返回false时停止
+	Range(fn func(conn Conn) bool)
+	// Count 返回当前的连接数
+	Count() int
+	// Kick 关闭指定ID的连接，连接不存在时返回false
+	Kick(id string) bool
+
+	// Broadcast 向所有连接发送消息，返回成功和被丢弃的数量
+	Broadcast(msg []byte) (delivered, dropped int, err error)
+	// Multicast 向指定ID的连接发送消息，返回成功和被丢弃的数量
+	Multicast(ids []string, msg []byte) (delivered, dropped int, err error)
+
+	// Join 将连接加入房间，连接断开时自动退出所有房间
+	Join(room string, conn Conn)
+	// Leave 将连接移出房间
+	Leave(room string, conn Conn)
+	// PublishToRoom 向房间内的所有成员发送消息，返回成功和被丢弃的数量
+	PublishToRoom(room string, msg []byte) (delivered, dropped int, err error)
+	// RoomSize 返回房间的成员数量
+	RoomSize(room string) int
 }
 
 func NewReactor(opts ...Option) EventLoop {
@@ -24,8 +77,15 @@ func NewReactor(opts ...Option) EventLoop {
 	reactor := &MainReactor{
 		EventHandler: new(EventHandler),
 		Engine:       newEngine(utils.RoundUp(option.ctxPoolSize)),
+		options:      option,
 		children:     make([]*SubReactor, utils.RoundUp(option.processor)),
+		registry:     newRegistry(),
+		rooms:        newRooms(),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
 	}
+	reactor.Engine.panicHandler = reactor.handlePanic
+	reactor.Engine.headerCodec, _ = option.codec.(HeaderCodec)
 	reactor.init()
 	return reactor
 }
@@ -1,17 +1,72 @@
 package linker
 
+import "time"
+
 type options struct {
-	processor   int
-	ctxPoolSize int
+	processor          int
+	ctxPoolSize        int
+	reusePort          int
+	sessionIdleTimeout time.Duration
+	codec              Codec
+	maxFrameSize       int
+	oversizePolicy     OversizePolicy
+	oversizeCallback   func(conn Conn, err error)
+	framesPerWakeup    int
+	writeHighWaterMark int
+	slowConsumerPolicy SlowConsumerPolicy
+	writeTimeout       time.Duration
+	readIdleTimeout    time.Duration
+	writeIdleTimeout   time.Duration
+	allIdleTimeout     time.Duration
+	maxMissedHeartbeat int
+	heartbeat          []byte
+	closeOnPanic       bool
+	websocketMessage   WebsocketMessageType
 }
 
 func defaultOption() *options {
 	return &options{
-		processor:   32,
-		ctxPoolSize: 32,
+		processor:          32,
+		ctxPoolSize:        32,
+		reusePort:          1,
+		sessionIdleTimeout: time.Minute,
+		codec:              NewLineCodec(),
+		maxFrameSize:       1 << 20,
+		oversizePolicy:     OversizeClose,
+		framesPerWakeup:    32,
+		writeHighWaterMark: 1 << 20,
+		slowConsumerPolicy: SlowConsumerDrop,
+		writeTimeout:       5 * time.Second,
+		websocketMessage:   WebsocketBinary,
 	}
 }
 
+// OversizePolicy 收到超过最大长度的帧时的处理策略
+type OversizePolicy int
+
+const (
+	OversizeClose    OversizePolicy = iota // 关闭连接
+	OversizeSkip                           // 丢弃该帧
+	OversizeCallback                       // 调用回调函数后丢弃该帧
+)
+
+// SlowConsumerPolicy 发送队列超出高水位时的处理策略
+type SlowConsumerPolicy int
+
+const (
+	SlowConsumerDrop       SlowConsumerPolicy = iota // 丢弃消息，Push返回ErrWriteQueueFull
+	SlowConsumerBlock                                // 阻塞等待直至超时，超时后Push返回ErrWriteTimeout
+	SlowConsumerDisconnect                           // 断开连接，Push返回ErrWriteQueueFull
+)
+
+// WebsocketMessageType Push发送的websocket数据帧的类型
+type WebsocketMessageType byte
+
+const (
+	WebsocketBinary WebsocketMessageType = WebsocketMessageType(opBinary) // 二进制帧
+	WebsocketText   WebsocketMessageType = WebsocketMessageType(opText)   // 文本帧，消息需要是合法的UTF-8
+)
+
 type Option func(opts *options)
 
 func WithProcessor(n int) Option {
@@ -25,3 +80,125 @@ func WithContextPoolSize(n int) Option {
 		opts.ctxPoolSize = n
 	}
 }
+
+// WithReusePort 开启SO_REUSEPORT，监听n个UDP socket，由内核按远端地址分配数据报
+func WithReusePort(n int) Option {
+	return func(opts *options) {
+		opts.reusePort = n
+	}
+}
+
+// WithSessionIdleTimeout UDP会话超过指定时间未收到数据报时关闭，不大于0时不关闭空闲会话
+func WithSessionIdleTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.sessionIdleTimeout = timeout
+	}
+}
+
+// WithCodec 设置TCP连接的编解码器，默认以换行符切分
+func WithCodec(codec Codec) Option {
+	return func(opts *options) {
+		opts.codec = codec
+	}
+}
+
+// WithFramesPerWakeup 每次就绪事件最多处理单个连接的帧数，超出部分会在其他连接之后继续处理
+func WithFramesPerWakeup(n int) Option {
+	return func(opts *options) {
+		opts.framesPerWakeup = n
+	}
+}
+
+// WithMaxFrameSize 设置单个入站帧的最大长度，连接的读缓冲区最多扩容到该长度
+// websocket消息超长时总是以1009关闭连接
+func WithMaxFrameSize(n int) Option {
+	return func(opts *options) {
+		opts.maxFrameSize = n
+	}
+}
+
+// WithWebsocketMessageType 设置Push和广播发送的websocket数据帧类型，默认为二进制帧
+func WithWebsocketMessageType(t WebsocketMessageType) Option {
+	return func(opts *options) {
+		opts.websocketMessage = t
+	}
+}
+
+// WithOversizePolicy 设置超长帧的处理策略
+func WithOversizePolicy(policy OversizePolicy) Option {
+	return func(opts *options) {
+		opts.oversizePolicy = policy
+	}
+}
+
+// WithOversizeCallback 超长帧的回调函数，同时将策略设置为OversizeCallback
+func WithOversizeCallback(callback func(conn Conn, err error)) Option {
+	return func(opts *options) {
+		opts.oversizePolicy = OversizeCallback
+		opts.oversizeCallback = callback
+	}
+}
+
+// WithWriteHighWaterMark 设置单个连接发送队列的最大字节数
+func WithWriteHighWaterMark(n int) Option {
+	return func(opts *options) {
+		opts.writeHighWaterMark = n
+	}
+}
+
+// WithSlowConsumerPolicy 设置发送队列超出高水位时的处理策略
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy) Option {
+	return func(opts *options) {
+		opts.slowConsumerPolicy = policy
+	}
+}
+
+// WithWriteTimeout 设置SlowConsumerBlock策略下Push的最长等待时间
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.writeTimeout = timeout
+	}
+}
+
+// WithReadIdleTimeout 连接超过指定时间未收到数据时触发IdleRead事件，0表示不检测
+func WithReadIdleTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.readIdleTimeout = timeout
+	}
+}
+
+// WithWriteIdleTimeout 连接超过指定时间未发送数据时触发IdleWrite事件，0表示不检测
+func WithWriteIdleTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.writeIdleTimeout = timeout
+	}
+}
+
+// WithAllIdleTimeout 连接超过指定时间既未收到也未发送数据时触发IdleAll事件，0表示不检测
+func WithAllIdleTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.allIdleTimeout = timeout
+	}
+}
+
+// WithMaxMissedHeartbeat 连续n次触发IdleRead事件后关闭连接，0表示不关闭
+func WithMaxMissedHeartbeat(n int) Option {
+	return func(opts *options) {
+		opts.maxMissedHeartbeat = n
+	}
+}
+
+// WithHeartbeat 触发IdleRead事件时由服务端发送心跳，websocket连接发送以msg为负载的ping帧，
+// 其他连接经过编解码器发送msg
+func WithHeartbeat(msg []byte) Option {
+	return func(opts *options) {
+		opts.heartbeat = msg
+	}
+}
+
+// WithCloseOnPanic 处理请求时发生panic后关闭该连接，其他连接不受影响
+func WithCloseOnPanic(close bool) Option {
+	return func(opts *options) {
+		opts.closeOnPanic = close
+	}
+}
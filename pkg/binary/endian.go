@@ -4,9 +4,24 @@ import (
 	"bytes"
 )
 
+// ByteOrder 整数的字节序
+type ByteOrder interface {
+	Int8(b []byte) int8
+	PutInt8(b []byte, v int8)
+	Int16(b []byte) int16
+	PutInt16(b []byte, v int16)
+	Int32(b []byte) int32
+	PutInt32(b []byte, v int32)
+	Int64(b []byte) int64
+	PutInt64(b []byte, v int64)
+}
+
 // BigEndian big endian.
 var BigEndian bigEndian
 
+// LittleEndian little endian.
+var LittleEndian littleEndian
+
 type bigEndian struct{}
 
 func (bigEndian) Int8(b []byte) int8 { return int8(b[0]) }
@@ -33,6 +48,22 @@ func (bigEndian) PutInt32(b []byte, v int32) {
 	b[3] = byte(v)
 }
 
+func (bigEndian) Int64(b []byte) int64 {
+	return int64(b[7]) | int64(b[6])<<8 | int64(b[5])<<16 | int64(b[4])<<24 |
+		int64(b[3])<<32 | int64(b[2])<<40 | int64(b[1])<<48 | int64(b[0])<<56
+}
+
+func (bigEndian) PutInt64(b []byte, v int64) {
+	b[0] = byte(v >> 56)
+	b[1] = byte(v >> 48)
+	b[2] = byte(v >> 40)
+	b[3] = byte(v >> 32)
+	b[4] = byte(v >> 24)
+	b[5] = byte(v >> 16)
+	b[6] = byte(v >> 8)
+	b[7] = byte(v)
+}
+
 func (bigEndian) String(b []byte) string {
 	index := bytes.IndexByte(b, 0)
 	if index < 0 {
@@ -51,3 +82,45 @@ func (bigEndian) PutString(b []byte, v string) {
 		}
 	}
 }
+
+type littleEndian struct{}
+
+func (littleEndian) Int8(b []byte) int8 { return int8(b[0]) }
+
+func (littleEndian) PutInt8(b []byte, v int8) {
+	b[0] = byte(v)
+}
+
+func (littleEndian) Int16(b []byte) int16 { return int16(b[0]) | int16(b[1])<<8 }
+
+func (littleEndian) PutInt16(b []byte, v int16) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+}
+
+func (littleEndian) Int32(b []byte) int32 {
+	return int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16 | int32(b[3])<<24
+}
+
+func (littleEndian) PutInt32(b []byte, v int32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
+
+func (littleEndian) Int64(b []byte) int64 {
+	return int64(b[0]) | int64(b[1])<<8 | int64(b[2])<<16 | int64(b[3])<<24 |
+		int64(b[4])<<32 | int64(b[5])<<40 | int64(b[6])<<48 | int64(b[7])<<56
+}
+
+func (littleEndian) PutInt64(b []byte, v int64) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+	b[4] = byte(v >> 32)
+	b[5] = byte(v >> 40)
+	b[6] = byte(v >> 48)
+	b[7] = byte(v >> 56)
+}
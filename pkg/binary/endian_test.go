@@ -28,3 +28,27 @@ func TestInt32(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestInt64(t *testing.T) {
+	b := make([]byte, 8)
+	BigEndian.PutInt64(b, 1<<40+100)
+	if BigEndian.Int64(b) != 1<<40+100 || b[7] != 100 {
+		t.FailNow()
+	}
+}
+
+func TestLittleEndian(t *testing.T) {
+	b := make([]byte, 8)
+	LittleEndian.PutInt16(b, 0x0102)
+	if LittleEndian.Int16(b) != 0x0102 || b[0] != 0x02 {
+		t.FailNow()
+	}
+	LittleEndian.PutInt32(b, 0x01020304)
+	if LittleEndian.Int32(b) != 0x01020304 || b[0] != 0x04 {
+		t.FailNow()
+	}
+	LittleEndian.PutInt64(b, -100)
+	if LittleEndian.Int64(b) != -100 {
+		t.FailNow()
+	}
+}
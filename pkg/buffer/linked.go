@@ -11,8 +11,9 @@ var (
 )
 
 type node struct {
-	buf  []byte
-	next *node
+	buf    []byte
+	next   *node
+	shared bool // buf is not owned by the Buffer and must not be returned to the pool
 }
 
 func (b *node) len() int {
@@ -41,7 +42,7 @@ func (llb *Buffer) Read(p []byte) (n int, err error) {
 			b.buf = b.buf[m:]
 			llb.pushFront(b)
 		} else {
-			bsPool.Put(b.buf)
+			llb.free(b)
 		}
 		if n == len(p) {
 			return
@@ -72,6 +73,15 @@ func (llb *Buffer) PushBack(p []byte) {
 	llb.pushBack(&node{buf: b})
 }
 
+// PushBackShared appends p without copying it. p is shared with the caller
+// and other Buffers, so it must not be modified and is never returned to the pool.
+func (llb *Buffer) PushBackShared(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	llb.pushBack(&node{buf: p, shared: true})
+}
+
 // Peek assembles the up to maxBytes of [][]byte based on the list of node,
 // it won't remove these nodes from l until Discard() is called.
 func (llb *Buffer) Peek(maxBytes int) [][]byte {
@@ -131,7 +141,7 @@ func (llb *Buffer) Discard(n int) (discarded int, err error) {
 		}
 		n -= b.len()
 		discarded += b.len()
-		bsPool.Put(b.buf)
+		llb.free(b)
 	}
 	return
 }
@@ -178,7 +188,7 @@ func (llb *Buffer) WriteTo(w io.Writer) (n int64, err error) {
 			llb.pushFront(b)
 			return n, io.ErrShortWrite
 		}
-		bsPool.Put(b.buf)
+		llb.free(b)
 	}
 	return
 }
@@ -201,7 +211,7 @@ func (llb *Buffer) IsEmpty() bool {
 // Reset removes all elements from this list.
 func (llb *Buffer) Reset() {
 	for b := llb.pop(); b != nil; b = llb.pop() {
-		bsPool.Put(b.buf)
+		llb.free(b)
 	}
 	llb.head = nil
 	llb.tail = nil
@@ -210,6 +220,13 @@ func (llb *Buffer) Reset() {
 	llb.bs = llb.bs[:0]
 }
 
+// free returns the buf of b to the pool unless it is shared.
+func (llb *Buffer) free(b *node) {
+	if !b.shared {
+		bsPool.Put(b.buf)
+	}
+}
+
 // pop returns and removes the head of l. If l is empty, it returns nil.
 func (llb *Buffer) pop() *node {
 	if llb.head == nil {
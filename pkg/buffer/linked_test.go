@@ -0,0 +1,28 @@
+package buffer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPushBackShared(t *testing.T) {
+	shared := []byte("hello")
+	var a, b Buffer
+	a.PushBackShared(shared)
+	b.PushBackShared(shared)
+	b.PushBack([]byte(",world"))
+	assert.Equal(t, 5, a.Buffered())
+	assert.Equal(t, 11, b.Buffered())
+
+	_, _ = a.Discard(5)
+	b.Reset()
+	assert.True(t, a.IsEmpty())
+	assert.True(t, b.IsEmpty())
+	assert.Equal(t, "hello", string(shared))
+
+	a.PushBackShared(shared)
+	p := make([]byte, 3)
+	n, _ := a.Read(p)
+	assert.Equal(t, "hel", string(p[:n]))
+	assert.Equal(t, [][]byte{[]byte("lo")}, a.Peek(0))
+}
@@ -108,6 +108,9 @@ func (rb *RingBuffer) Reset() {
 
 // Buffered 获取已缓存的数据长度
 func (rb *RingBuffer) Buffered() int {
+	if rb.isEmpty {
+		return 0
+	}
 	if rb.w > rb.r {
 		return rb.w - rb.r
 	}
@@ -128,7 +131,7 @@ func (rb *RingBuffer) Available() int {
 		return rb.r - rb.w
 	}
 
-	return rb.size - rb.r + rb.w
+	return rb.size - rb.w + rb.r
 }
 
 func (rb *RingBuffer) Write(p []byte) (n int, err error) {
@@ -233,9 +236,7 @@ func (rb *RingBuffer) grow(newCap int) {
 	rb.r = 0                     // 从0开始读取
 	rb.w = oldLen                // 标记读取位置
 	rb.size = newCap             // 更新
-	if rb.w > 0 {                // 判断是否为空
-		rb.isEmpty = true
-	}
+	rb.isEmpty = oldLen == 0     // 判断是否为空
 }
 
 // Read 读取数据
@@ -15,3 +15,27 @@ func TestRing(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, []byte("hello"), s)
 }
+
+func TestRingWrap(t *testing.T) {
+	rb := NewRingBuffer(8)
+	assert.Equal(t, 0, rb.Buffered())
+	assert.Equal(t, 8, rb.Available())
+
+	_, _ = rb.Write([]byte("abcdef"))
+	_, _ = rb.Discard(4)
+	assert.Equal(t, 2, rb.Buffered())
+	assert.Equal(t, 6, rb.Available())
+
+	_, _ = rb.Write([]byte("ghij"))
+	head, tail := rb.Peek(0)
+	assert.Equal(t, "efgh", string(head))
+	assert.Equal(t, "ij", string(tail))
+
+	// 扩容后数据保持不变
+	_, _ = rb.Write([]byte("klmnopq"))
+	assert.Equal(t, 13, rb.Buffered())
+	p := make([]byte, 13)
+	n, _ := rb.Read(p)
+	assert.Equal(t, "efghijklmnopq", string(p[:n]))
+	assert.True(t, rb.IsEmpty())
+}
@@ -26,27 +26,52 @@ func (impl *Epoll) Add(fd int) error {
 		&unix.EpollEvent{Events: unix.POLLIN | unix.POLLHUP, Fd: int32(fd)})
 }
 
+func (impl *Epoll) EnableWrite(fd int) error {
+	return unix.EpollCtl(impl.fd,
+		unix.EPOLL_CTL_MOD,
+		fd,
+		&unix.EpollEvent{Events: unix.POLLIN | unix.POLLHUP | unix.POLLOUT, Fd: int32(fd)})
+}
+
+func (impl *Epoll) DisableWrite(fd int) error {
+	return unix.EpollCtl(impl.fd,
+		unix.EPOLL_CTL_MOD,
+		fd,
+		&unix.EpollEvent{Events: unix.POLLIN | unix.POLLHUP, Fd: int32(fd)})
+}
+
 func (impl *Epoll) Remove(fd int) error {
 	// 向 epoll 实例删除文件描述符对应的事件
 	return unix.EpollCtl(impl.fd, syscall.EPOLL_CTL_DEL, fd, nil)
 }
 
-func (impl *Epoll) Wait() ([]int, error) {
+func (impl *Epoll) Wait() ([]int, []int, error) {
 	events := make([]unix.EpollEvent, impl.maxEventSize)
 	n, err := unix.EpollWait(impl.fd, events, 100)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	read := make([]int, 0, impl.maxEventSize)
+	read := make([]int, 0, n)
+	var write []int
 	for i := 0; i < n; i++ {
 		if events[i].Fd == 0 {
 			continue
 		}
-		read = append(read, int(events[i].Fd))
+		// 出错或挂起时交给读事件处理，由读取的结果关闭连接
+		if events[i].Events&(unix.EPOLLIN|unix.EPOLLHUP|unix.EPOLLERR|unix.EPOLLRDHUP) != 0 {
+			read = append(read, int(events[i].Fd))
+		}
+		if events[i].Events&unix.EPOLLOUT != 0 {
+			write = append(write, int(events[i].Fd))
+		}
 	}
 
-	return read, nil
+	return read, write, nil
+}
+
+func (impl *Epoll) Close() error {
+	return unix.Close(impl.fd)
 }
 
 func CreateEpoll() (*Epoll, error) {
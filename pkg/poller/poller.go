@@ -8,7 +8,13 @@ import (
 type Poller interface {
 	Add(fd int) error
 	Remove(fd int) error
-	Wait() (read []int, err error)
+	// EnableWrite 关注可写事件，用于发送缓冲区中剩余的数据
+	EnableWrite(fd int) error
+	// DisableWrite 取消关注可写事件
+	DisableWrite(fd int) error
+	Wait() (read []int, write []int, err error)
+	// Close 释放poller占用的文件描述符
+	Close() error
 }
 
 // SocketFD get socket connection fd
@@ -1,34 +1,75 @@
 package pool
 
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicHandler 任务panic时调用，stack为panic时的调用栈
+type PanicHandler func(r interface{}, stack []byte)
+
+// defaultPanicHandler 打印panic信息和调用栈
+func defaultPanicHandler(r interface{}, stack []byte) {
+	log.Printf("worker panic: %v\n%s", r, stack)
+}
+
+// recoverPanic 恢复任务中的panic，避免整个进程退出
+func recoverPanic(handler PanicHandler) {
+	if r := recover(); r != nil {
+		handler(r, debug.Stack())
+	}
+}
+
 type Worker interface {
 	Schedule(fn func())
+	// Wait 等待所有已提交的任务执行完成
+	Wait()
 }
 type WorkerPool struct {
-	size int
-	task chan struct{}
+	size         int
+	task         chan struct{}
+	wg           sync.WaitGroup
+	panicHandler PanicHandler
 }
 
 func NewWorkerPool(size int) *WorkerPool {
-	pool := &WorkerPool{size: size, task: make(chan struct{}, size)}
+	pool := &WorkerPool{size: size, task: make(chan struct{}, size), panicHandler: defaultPanicHandler}
+	return pool
+}
+
+// WithPanicHandler 设置任务panic时的处理函数，默认打印调用栈
+func (pool *WorkerPool) WithPanicHandler(handler PanicHandler) *WorkerPool {
+	pool.panicHandler = handler
 	return pool
 }
 
 // Submit run some task
-func (pool WorkerPool) Schedule(fn func()) {
+func (pool *WorkerPool) Schedule(fn func()) {
 	pool.task <- struct{}{}
+	pool.wg.Add(1)
 	go func() {
+		defer pool.wg.Done()
+		defer func() { <-pool.task }()
+		defer recoverPanic(pool.panicHandler)
 		fn()
-		<-pool.task
 	}()
 }
 
+func (pool *WorkerPool) Wait() {
+	pool.wg.Wait()
+}
+
 type GoroutinePool struct {
-	work chan func()
+	work         chan func()
+	wg           sync.WaitGroup
+	panicHandler PanicHandler
 }
 
 func NewGoroutinePool(size int) *GoroutinePool {
 	gp := &GoroutinePool{
-		work: make(chan func(), size),
+		work:         make(chan func(), size),
+		panicHandler: defaultPanicHandler,
 	}
 
 	for i := 0; i < size; i++ {
@@ -37,16 +78,35 @@ func NewGoroutinePool(size int) *GoroutinePool {
 	return gp
 }
 func (p *GoroutinePool) Schedule(task func()) {
+	p.wg.Add(1)
 	select {
 	case p.work <- task:
 	default:
+		p.wg.Done()
 	}
 }
 
+// WithPanicHandler 设置任务panic时的处理函数，默认打印调用栈
+func (p *GoroutinePool) WithPanicHandler(handler PanicHandler) *GoroutinePool {
+	p.panicHandler = handler
+	return p
+}
+
+func (p *GoroutinePool) Wait() {
+	p.wg.Wait()
+}
+
 func (p *GoroutinePool) run() {
 	var task func()
 	for {
 		task = <-p.work
-		task()
+		p.execute(task)
 	}
 }
+
+// execute 任务panic时协程不会退出
+func (p *GoroutinePool) execute(task func()) {
+	defer p.wg.Done()
+	defer recoverPanic(p.panicHandler)
+	task()
+}
@@ -2,6 +2,7 @@ package pool
 
 import (
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
@@ -16,3 +17,37 @@ func TestNewGoroutinePool(t *testing.T) {
 	}
 
 }
+
+func TestWorkerPoolPanic(t *testing.T) {
+	recovered := make(chan interface{}, 1)
+	pool := NewWorkerPool(1).WithPanicHandler(func(r interface{}, stack []byte) {
+		assert.Contains(t, string(stack), "TestWorkerPoolPanic")
+		recovered <- r
+	})
+	pool.Schedule(func() {
+		panic("boom")
+	})
+	pool.Wait()
+	assert.Equal(t, "boom", <-recovered)
+
+	// panic之后仍然可以调度任务
+	done := make(chan struct{})
+	pool.Schedule(func() { close(done) })
+	<-done
+}
+
+func TestGoroutinePoolPanic(t *testing.T) {
+	recovered := make(chan interface{}, 1)
+	gp := NewGoroutinePool(1).WithPanicHandler(func(r interface{}, stack []byte) {
+		recovered <- r
+	})
+	gp.Schedule(func() {
+		panic("boom")
+	})
+	gp.Wait()
+	assert.Equal(t, "boom", <-recovered)
+
+	done := make(chan struct{})
+	gp.Schedule(func() { close(done) })
+	<-done
+}
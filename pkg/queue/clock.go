@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 延时队列使用的时钟，时间均为毫秒时间戳
+type Clock interface {
+	Now() int64
+	// Until 返回在时钟到达deadline时触发的channel
+	Until(deadline int64) <-chan time.Time
+}
+
+// funcClock 以nowF读取时间，以系统定时器等待
+type funcClock func() int64
+
+func (nowF funcClock) Now() int64 {
+	return nowF()
+}
+
+func (nowF funcClock) Until(deadline int64) <-chan time.Time {
+	return time.After(time.Duration(deadline-nowF()) * time.Millisecond)
+}
+
+// ManualClock 手动推进的时钟，用于测试定时任务
+type ManualClock struct {
+	mu      sync.Mutex
+	now     int64
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	deadline int64
+	c        chan time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start.UnixMilli()}
+}
+
+func (clock *ManualClock) Now() int64 {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *ManualClock) Until(deadline int64) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if deadline <= clock.now {
+		c <- time.UnixMilli(clock.now)
+		return c
+	}
+	clock.waiters = append(clock.waiters, manualWaiter{deadline: deadline, c: c})
+	return c
+}
+
+// Advance 将时钟推进d，唤醒所有已到期的等待
+func (clock *ManualClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now += d.Milliseconds()
+	waiters := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.deadline <= clock.now {
+			waiter.c <- time.UnixMilli(clock.now)
+		} else {
+			waiters = append(waiters, waiter)
+		}
+	}
+	clock.waiters = waiters
+}
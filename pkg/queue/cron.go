@@ -0,0 +1,411 @@
+package queue
+
+import (
+	"fmt"
+	"linker/pkg/pool"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CronSchedule 解析后的cron表达式，每个字段以位图表示允许的取值
+type CronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	location                              *time.Location
+}
+
+// starBit 字段为*或?时设置，用于判断日期和星期的匹配方式
+const starBit = 1 << 63
+
+type cronBounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = cronBounds{min: 0, max: 59}
+	minuteBounds = cronBounds{min: 0, max: 59}
+	hourBounds   = cronBounds{min: 0, max: 23}
+	domBounds    = cronBounds{min: 1, max: 31}
+	monthBounds  = cronBounds{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = cronBounds{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// ParseCron 解析cron表达式，支持5个字段(分 时 日 月 周)或者6个字段(秒 分 时 日 月 周)，
+// 以及@daily等描述符。可以用CRON_TZ=Asia/Shanghai前缀指定时区，默认使用time.Local
+func ParseCron(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	location := time.Local
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexAny(spec, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("cron: missing fields in %q", spec)
+		}
+		name := spec[strings.Index(spec, "=")+1 : i]
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("cron: invalid time zone %q: %v", name, err)
+		}
+		location = loc
+		spec = strings.TrimSpace(spec[i:])
+	}
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: expected 5 or 6 fields, found %d in %q", len(fields), spec)
+	}
+
+	schedule := &CronSchedule{location: location}
+	var err error
+	targets := []*uint64{&schedule.second, &schedule.minute, &schedule.hour, &schedule.dom, &schedule.month, &schedule.dow}
+	bounds := []cronBounds{secondBounds, minuteBounds, hourBounds, domBounds, monthBounds, dowBounds}
+	for i, field := range fields {
+		if *targets[i], err = parseCronField(field, bounds[i]); err != nil {
+			return nil, err
+		}
+	}
+	// 周日可以写作0或者7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow = schedule.dow&^(1<<7) | 1
+	}
+	return schedule, nil
+}
+
+// parseCronField 解析以逗号分隔的字段，每一项为*、?、a、a-b，可以带/step
+func parseCronField(field string, bounds cronBounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		rangeAndStep := strings.Split(expr, "/")
+		if len(rangeAndStep) > 2 {
+			return 0, fmt.Errorf("cron: invalid step in %q", expr)
+		}
+		start, end, step := bounds.min, bounds.max, 1
+		star := false
+		switch low := strings.Split(rangeAndStep[0], "-"); {
+		case rangeAndStep[0] == "*" || rangeAndStep[0] == "?":
+			star = true
+		case len(low) == 1:
+			v, err := parseCronValue(low[0], bounds)
+			if err != nil {
+				return 0, err
+			}
+			start, end = v, v
+			if len(rangeAndStep) == 2 { // a/step 表示从a到最大值
+				end = bounds.max
+			}
+		case len(low) == 2:
+			var err error
+			if start, err = parseCronValue(low[0], bounds); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(low[1], bounds); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("cron: invalid range in %q", expr)
+		}
+		if len(rangeAndStep) == 2 {
+			var err error
+			if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("cron: invalid step in %q", expr)
+			}
+			star = false
+		}
+		if start > end {
+			return 0, fmt.Errorf("cron: invalid range in %q", expr)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+		if star {
+			bits |= starBit
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, bounds cronBounds) (int, error) {
+	if v, ok := bounds.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < bounds.min || v > bounds.max {
+		return 0, fmt.Errorf("cron: value %q out of range [%d, %d]", s, bounds.min, bounds.max)
+	}
+	return v, nil
+}
+
+// Next 返回t之后的下一个触发时间，5年内没有匹配的时间时返回零值
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	loc := schedule.location
+	t = t.In(loc).Add(time.Second - time.Duration(t.Nanosecond())).Truncate(time.Second)
+	yearLimit := t.Year() + 5
+	added := false
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for schedule.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !schedule.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for schedule.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for schedule.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	for schedule.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+// dayMatches 日期和星期都有限制时满足其一即可，否则都需要满足
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	dom := schedule.dom&(1<<uint(t.Day())) != 0
+	dow := schedule.dow&(1<<uint(t.Weekday())) != 0
+	if schedule.dom&starBit != 0 || schedule.dow&starBit != 0 {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Cron 按照cron表达式调度任务，以DelayQueue按下一次触发时间排序，任务在worker pool中执行
+type Cron struct {
+	queue  *DelayQueue[*CronJob]
+	worker pool.Worker
+	clock  Clock
+
+	exitC chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// CronJob cron中的任务
+type CronJob struct {
+	schedule      *CronSchedule
+	fn            func()
+	jitter        time.Duration
+	skipIfRunning bool
+
+	mu      sync.Mutex
+	next    time.Time // 下一次触发时间，不包括随机延迟
+	item    *Item[*CronJob]
+	stopped bool
+	running int32
+}
+
+// CronOption 任务的选项
+type CronOption func(job *CronJob)
+
+// WithJitter 每次触发时随机延迟[0, jitter)，避免多个实例同时执行
+func WithJitter(jitter time.Duration) CronOption {
+	return func(job *CronJob) {
+		job.jitter = jitter
+	}
+}
+
+// WithSkipIfRunning 上一次执行未结束时跳过本次触发
+func WithSkipIfRunning() CronOption {
+	return func(job *CronJob) {
+		job.skipIfRunning = true
+	}
+}
+
+// NewCron worker为nil时每个任务在新的协程中执行
+func NewCron(worker pool.Worker) *Cron {
+	return &Cron{
+		queue:  NewDelayQueue[*CronJob](64),
+		worker: worker,
+		clock: funcClock(func() int64 {
+			return time.Now().UnixMilli()
+		}),
+		exitC: make(chan struct{}),
+	}
+}
+
+// WithClock 替换时钟，任务的触发时间和等待都由clock驱动，需要在Start之前调用
+func (cron *Cron) WithClock(clock Clock) *Cron {
+	cron.clock = clock
+	return cron
+}
+
+// Start 启动调度协程
+func (cron *Cron) Start() {
+	cron.wg.Add(2)
+	go func() {
+		defer cron.wg.Done()
+		cron.queue.PollClock(cron.exitC, cron.clock)
+	}()
+	go func() {
+		defer cron.wg.Done()
+		cron.run()
+	}()
+}
+
+// Stop 停止调度，不等待执行中的任务
+func (cron *Cron) Stop() {
+	cron.once.Do(func() {
+		close(cron.exitC)
+		cron.wg.Wait()
+	})
+}
+
+// AddFunc 按照spec调度fn，spec的格式见ParseCron
+func (cron *Cron) AddFunc(spec string, fn func(), opts ...CronOption) (*CronJob, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	job := &CronJob{schedule: schedule, fn: fn}
+	for _, setter := range opts {
+		setter(job)
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if !job.reschedule(cron, cron.now()) {
+		return nil, fmt.Errorf("cron: %q never fires", spec)
+	}
+	return job, nil
+}
+
+func (cron *Cron) now() time.Time {
+	return time.UnixMilli(cron.clock.Now())
+}
+
+func (cron *Cron) run() {
+	for {
+		select {
+		case job := <-cron.queue.C:
+			cron.execute(job)
+		case <-cron.exitC:
+			return
+		}
+	}
+}
+
+func (cron *Cron) execute(job *CronJob) {
+	job.mu.Lock()
+	if job.stopped {
+		job.mu.Unlock()
+		return
+	}
+	// 从本次的触发时间开始计算，避免时钟误差导致重复触发
+	base := cron.now()
+	if job.next.After(base) {
+		base = job.next
+	}
+	job.reschedule(cron, base)
+	job.mu.Unlock()
+
+	if job.skipIfRunning && !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		return
+	}
+	task := func() {
+		defer atomic.StoreInt32(&job.running, 0)
+		job.fn()
+	}
+	if cron.worker == nil {
+		go task()
+		return
+	}
+	cron.worker.Schedule(task)
+}
+
+// reschedule 计算下一次触发时间并放入队列，调用时需持有锁
+func (job *CronJob) reschedule(cron *Cron, after time.Time) bool {
+	job.next = job.schedule.Next(after)
+	if job.next.IsZero() {
+		return false
+	}
+	expiration := job.next.UnixMilli()
+	if jitter := job.jitter.Milliseconds(); jitter > 0 {
+		expiration += rand.Int63n(jitter)
+	}
+	if job.item == nil {
+		job.item, _ = cron.queue.Offer(job, expiration)
+	} else {
+		_ = job.item.Reset(expiration)
+	}
+	return true
+}
+
+// Next 返回下一次触发时间，不包括随机延迟
+func (job *CronJob) Next() time.Time {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.next
+}
+
+// Stop 取消任务，不会中断执行中的任务
+func (job *CronJob) Stop() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.stopped = true
+	job.item.Cancel()
+}
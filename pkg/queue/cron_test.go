@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "CRON_TZ=Mars/Base * * * * *"} {
+		_, err := ParseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	from := time.Date(2024, 1, 31, 23, 59, 30, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"CRON_TZ=UTC * * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC */15 * * * * *", time.Date(2024, 1, 31, 23, 59, 45, 0, time.UTC)},
+		{"CRON_TZ=UTC 30 9 * * mon-fri", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 12 1,15 * 0", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC @monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=Asia/Shanghai 0 8 * * *", time.Date(2024, 2, 1, 8, 0, 0, 0, shanghai)},
+		{"CRON_TZ=UTC 0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.spec)
+		assert.Nil(t, err, c.spec)
+		assert.True(t, c.next.Equal(schedule.Next(from)), "%s: %s", c.spec, schedule.Next(from))
+	}
+
+	schedule, _ := ParseCron("0 0 30 2 *")
+	assert.True(t, schedule.Next(from).IsZero())
+}
+
+func TestCronSkipIfRunning(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 500e6, time.UTC))
+	cron := NewCron(nil).WithClock(clock)
+	cron.Start()
+	defer cron.Stop()
+
+	var started int32
+	release := make(chan struct{})
+	job, err := cron.AddFunc("CRON_TZ=UTC * * * * * *", func() {
+		if atomic.AddInt32(&started, 1) == 1 {
+			<-release
+		}
+	}, WithSkipIfRunning(), WithJitter(100*time.Millisecond))
+	assert.Nil(t, err)
+	// advance 推进一秒并等待本次触发处理完毕
+	advance := func() {
+		next := job.Next()
+		clock.Advance(time.Second)
+		assert.Eventually(t, func() bool {
+			return job.Next().After(next)
+		}, time.Second, time.Millisecond)
+	}
+
+	advance()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&started) == 1
+	}, time.Second, time.Millisecond)
+
+	// 第一次执行阻塞期间的触发被跳过
+	advance()
+	advance()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+	close(release)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&job.running) == 0
+	}, time.Second, time.Millisecond)
+	advance()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&started) == 2
+	}, time.Second, time.Millisecond)
+
+	job.Stop()
+	clock.Advance(2 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&started))
+}
+
+func TestCronClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 59, 59, 0, time.UTC))
+	cron := NewCron(nil).WithClock(clock)
+	cron.Start()
+	defer cron.Stop()
+
+	fired := make(chan struct{}, 1)
+	job, err := cron.AddFunc("CRON_TZ=UTC 0 10 * * *", func() {
+		fired <- struct{}{}
+	})
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC).Equal(job.Next()))
+
+	// 时钟未推进时不会触发
+	select {
+	case <-fired:
+		t.Fatal("job fired before the clock advanced")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("job not fired")
+	}
+	// 执行前已经计算出下一次触发时间
+	assert.True(t, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).Equal(job.Next()))
+}
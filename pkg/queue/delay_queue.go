@@ -2,38 +2,64 @@ package queue
 
 import (
 	"container/heap"
+	"errors"
 	"sync"
 	"sync/atomic"
-	"time"
 )
 
+var ErrQueueFull = errors.New("queue: delay queue is full")
+
 // DelayQueue 延时队列
-type DelayQueue struct {
-	C        chan interface{} // 利用channel来传输任务
-	wakeupC  chan struct{}    // 有新任务就唤醒队列
+type DelayQueue[T any] struct {
+	C        chan T        // 利用channel来传输任务
+	wakeupC  chan struct{} // 有新任务就唤醒队列
 	mu       sync.Mutex
-	pq       priorityQueue // 延时队列
-	sleeping int32         // 是否为睡眠状态
+	pq       priorityQueue[T] // 延时队列
+	sleeping int32            // 是否为睡眠状态
+	capacity int              // 最多容纳的任务数，0表示不限制
+	journal  journal[T]       // 持久化模式下记录任务的变化
+}
+
+// journal 持久化模式下记录任务的加入和移除，在更新堆之后调用，调用时持有队列的锁
+type journal[T any] interface {
+	offer(item *Item[T], expiration int64) error
+	remove(item *Item[T]) error
+	close() error
 }
 
-func NewDelayQueue(size int) *DelayQueue {
-	return &DelayQueue{
-		C:       make(chan interface{}),
-		pq:      newPriorityQueue(size),
-		wakeupC: make(chan struct{}),
+// NewDelayQueue size为堆数组的初始容量，不大于0时使用默认值
+func NewDelayQueue[T any](size int) *DelayQueue[T] {
+	return &DelayQueue[T]{
+		C:       make(chan T),
+		pq:      newPriorityQueue[T](size),
+		wakeupC: make(chan struct{}, 1), // 避免Poll退出后Offer阻塞
 	}
 }
 
-type Item struct {
-	value    interface{}
+// WithCapacity 限制队列中的任务数，超出时Offer返回ErrQueueFull
+func (queue *DelayQueue[T]) WithCapacity(capacity int) *DelayQueue[T] {
+	queue.capacity = capacity
+	return queue
+}
+
+// Item 队列中的任务，可以通过Cancel取消或者通过Reset修改到期时间
+type Item[T any] struct {
+	value    T
 	priority int64 // 优先级
-	index    int
+	index    int   // 在堆中的位置，不在队列中时为-1
+	queue    *DelayQueue[T]
+	id       uint64 // 持久化模式下的唯一ID
+}
+
+// Poll 拉取任务，nowF返回当前的毫秒时间戳
+func (queue *DelayQueue[T]) Poll(exitC chan struct{}, nowF func() int64) {
+	queue.PollClock(exitC, funcClock(nowF))
 }
 
-// Poll 拉取任务
-func (queue *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
+// PollClock 按照clock拉取任务，等待也由clock驱动
+func (queue *DelayQueue[T]) PollClock(exitC chan struct{}, clock Clock) {
 	for {
-		now := nowF()
+		now := clock.Now()
 		queue.mu.Lock()
 
 		// 根据当前时间，获取一个新的任务
@@ -57,7 +83,7 @@ func (queue *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
 				select {
 				case <-queue.wakeupC: // 同上
 					continue
-				case <-time.After(time.Duration(delta) * time.Millisecond): // 时间到就执行
+				case <-clock.Until(now + delta): // 时间到就执行
 					if atomic.SwapInt32(&queue.sleeping, 0) == 0 {
 						<-queue.wakeupC
 					}
@@ -71,9 +97,15 @@ func (queue *DelayQueue) Poll(exitC chan struct{}, nowF func() int64) {
 		// 有任务满足执行的条件，将任务放入channel
 		select {
 		case queue.C <- item.value:
+			queue.delivered(item)
 		case <-exitC:
+			// 未送出的任务放回队列
+			queue.mu.Lock()
+			if item.index < 0 {
+				heap.Push(&queue.pq, item)
+			}
+			queue.mu.Unlock()
 			goto exit
-
 		}
 	}
 
@@ -82,66 +114,168 @@ exit:
 	atomic.StoreInt32(&queue.sleeping, 0)
 }
 
-// Offer 往队列里加入一个任务
-func (queue *DelayQueue) Offer(val interface{}, expiration int64) {
+// Offer 往队列里加入一个任务，返回的Item可以用于取消或者修改到期时间
+func (queue *DelayQueue[T]) Offer(val T, expiration int64) (*Item[T], error) {
 	// 把时间当做队列的优先级，时间越小，优先级越高，越先执行
-	item := &Item{value: val, priority: expiration}
+	item := &Item[T]{value: val, priority: expiration, queue: queue}
 
 	queue.mu.Lock()
+	if queue.capacity > 0 && queue.pq.Len() >= queue.capacity {
+		queue.mu.Unlock()
+		return nil, ErrQueueFull
+	}
 	heap.Push(&queue.pq, item) // 利用堆进行排序
+	// 先更新堆再写日志，压缩日志时以堆中的任务为准
+	if queue.journal != nil {
+		if err := queue.journal.offer(item, expiration); err != nil {
+			heap.Remove(&queue.pq, item.index)
+			queue.mu.Unlock()
+			return nil, err
+		}
+	}
 	index := item.index
 	queue.mu.Unlock()
 
-	if index == 0 { // 经过堆排序后的index为0,代表加入的是一个优先级更高的任务
+	queue.wakeup(index)
+	return item, nil
+}
+
+// delivered 任务已经送出，持久化模式下记录移除。送出后立即重新加入队列的任务不需要记录
+func (queue *DelayQueue[T]) delivered(item *Item[T]) {
+	if queue.journal == nil {
+		return
+	}
+	queue.mu.Lock()
+	if item.index < 0 {
+		_ = queue.journal.remove(item)
+	}
+	queue.mu.Unlock()
+}
+
+// Close 持久化模式下关闭日志文件
+func (queue *DelayQueue[T]) Close() error {
+	if queue.journal == nil {
+		return nil
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.journal.close()
+}
+
+// Len 返回队列中未到期的任务数
+func (queue *DelayQueue[T]) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.pq.Len()
+}
+
+// wakeup 经过堆排序后的index为0,代表加入的是一个优先级更高的任务
+func (queue *DelayQueue[T]) wakeup(index int) {
+	if index == 0 {
 		if atomic.CompareAndSwapInt32(&queue.sleeping, 1, 0) {
 			// 通知队列开始工作
 			queue.wakeupC <- struct{}{}
 		}
 	}
+}
 
+// Cancel 从队列中移除任务，任务已经到期或者已取消时返回false
+func (item *Item[T]) Cancel() bool {
+	queue := item.queue
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if item.index < 0 {
+		return false
+	}
+	heap.Remove(&queue.pq, item.index)
+	if queue.journal != nil {
+		_ = queue.journal.remove(item)
+	}
+	return true
+}
+
+// Reset 修改任务的到期时间，已经到期或者已取消的任务会重新加入队列
+func (item *Item[T]) Reset(expiration int64) error {
+	queue := item.queue
+	queue.mu.Lock()
+	if item.index < 0 && queue.capacity > 0 && queue.pq.Len() >= queue.capacity {
+		queue.mu.Unlock()
+		return ErrQueueFull
+	}
+	previous, queued := item.priority, item.index >= 0
+	item.priority = expiration
+	if queued {
+		heap.Fix(&queue.pq, item.index)
+	} else {
+		heap.Push(&queue.pq, item)
+	}
+	if queue.journal != nil {
+		if err := queue.journal.offer(item, expiration); err != nil {
+			// 写日志失败时恢复原来的状态
+			if queued {
+				item.priority = previous
+				heap.Fix(&queue.pq, item.index)
+			} else {
+				heap.Remove(&queue.pq, item.index)
+			}
+			queue.mu.Unlock()
+			return err
+		}
+	}
+	index := item.index
+	queue.mu.Unlock()
+
+	queue.wakeup(index)
+	return nil
 }
 
 // priorityQueue 优先级队列，利用堆结构进行排序
-type priorityQueue []*Item
+type priorityQueue[T any] []*Item[T]
 
-func newPriorityQueue(capacity int) priorityQueue {
-	return make(priorityQueue, 0, capacity)
+// defaultPriorityQueueSize size不大于0时堆数组的初始容量，容量为0时Push无法成倍扩容
+const defaultPriorityQueueSize = 16
+
+func newPriorityQueue[T any](capacity int) priorityQueue[T] {
+	if capacity <= 0 {
+		capacity = defaultPriorityQueueSize
+	}
+	return make(priorityQueue[T], 0, capacity)
 }
 
-func (queue priorityQueue) Len() int {
+func (queue priorityQueue[T]) Len() int {
 	return len(queue)
 }
 
-func (queue priorityQueue) Less(i, j int) bool {
+func (queue priorityQueue[T]) Less(i, j int) bool {
 	return queue[i].priority < queue[j].priority
 }
 
-func (queue priorityQueue) Swap(i, j int) {
+func (queue priorityQueue[T]) Swap(i, j int) {
 	queue[i], queue[j] = queue[j], queue[i]
 	queue[i].index = i
 	queue[j].index = j
 }
 
-func (queue *priorityQueue) Push(x any) {
+func (queue *priorityQueue[T]) Push(x any) {
 	n := len(*queue)
 	c := cap(*queue)
 	if n+1 > c { // 成倍扩容数组
-		temp := make(priorityQueue, n, c*2)
+		temp := make(priorityQueue[T], n, c*2)
 		copy(temp, *queue)
 		*queue = temp
 	}
 
 	*queue = (*queue)[:n+1]
-	item := x.(*Item)
+	item := x.(*Item[T])
 	item.index = n
 	(*queue)[n] = item
 }
 
-func (queue *priorityQueue) Pop() any {
+func (queue *priorityQueue[T]) Pop() any {
 	n := len(*queue)
 	c := cap(*queue)
 	if n < (c/2) && c > 25 { // 按倍缩容，且容量不能小于25
-		temp := make(priorityQueue, n, c/2)
+		temp := make(priorityQueue[T], n, c/2)
 		copy(temp, *queue)
 		*queue = temp
 	}
@@ -152,7 +286,7 @@ func (queue *priorityQueue) Pop() any {
 }
 
 // PeekAndShift 取第一个
-func (queue *priorityQueue) peekAndShift(max int64) (*Item, int64) {
+func (queue *priorityQueue[T]) peekAndShift(max int64) (*Item[T], int64) {
 	if queue.Len() == 0 {
 		return nil, 0
 	}
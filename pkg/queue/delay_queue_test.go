@@ -2,12 +2,13 @@ package queue
 
 import (
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
 
 func TestDelayQueue(t *testing.T) {
-	queue := NewDelayQueue(10)
+	queue := NewDelayQueue[string](10)
 	queue.Offer("a", 1)
 	queue.Offer("b", 2)
 	queue.Offer("c", 3)
@@ -35,3 +36,41 @@ func TestDelayQueue(t *testing.T) {
 	exitC <- struct{}{}
 
 }
+
+func TestDelayQueueCancel(t *testing.T) {
+	queue := NewDelayQueue[string](10).WithCapacity(2)
+	now := time.Now().UnixMilli()
+	a, err := queue.Offer("a", now+20)
+	assert.Nil(t, err)
+	b, err := queue.Offer("b", now+50)
+	assert.Nil(t, err)
+	_, err = queue.Offer("c", now+10)
+	assert.Equal(t, ErrQueueFull, err)
+	assert.Equal(t, 2, queue.Len())
+
+	assert.True(t, a.Cancel())
+	assert.False(t, a.Cancel())
+	assert.Equal(t, 1, queue.Len())
+	// b提前到期，已取消的a重新加入队列
+	assert.Nil(t, b.Reset(now+10))
+	assert.Nil(t, a.Reset(now+30))
+
+	exitC := make(chan struct{})
+	defer close(exitC)
+	go queue.Poll(exitC, func() int64 {
+		return time.Now().UnixMilli()
+	})
+	assert.Equal(t, "b", <-queue.C)
+	assert.Equal(t, "a", <-queue.C)
+	assert.Equal(t, 0, queue.Len())
+	assert.False(t, b.Cancel())
+}
+
+func TestDelayQueueZeroSize(t *testing.T) {
+	queue := NewDelayQueue[int](0)
+	for i := 0; i < 3*defaultPriorityQueueSize; i++ {
+		_, err := queue.Offer(i, int64(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3*defaultPriorityQueueSize, queue.Len())
+}
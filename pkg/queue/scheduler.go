@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"linker/pkg/pool"
+	"sync"
+	"time"
+)
+
+// Scheduler 基于DelayQueue的定时任务调度器，自行管理拉取协程，任务在worker pool中执行
+type Scheduler struct {
+	queue  *DelayQueue[*Task]
+	worker pool.Worker
+	nowF   func() int64 // 当前时间(毫秒)
+
+	exitC chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// Task 调度器中的任务
+type Task struct {
+	mu      sync.Mutex
+	fn      func()
+	period  int64 // 重复执行的间隔(毫秒)，0表示只执行一次
+	next    int64 // 下一次执行的时间(毫秒)
+	item    *Item[*Task]
+	stopped bool
+	started bool // 只执行一次的任务是否已经开始执行
+}
+
+// NewScheduler 创建并启动调度器，worker为nil时每个任务在新的协程中执行
+func NewScheduler(worker pool.Worker) *Scheduler {
+	scheduler := &Scheduler{
+		queue:  NewDelayQueue[*Task](64),
+		worker: worker,
+		nowF: func() int64 {
+			return time.Now().UnixMilli()
+		},
+		exitC: make(chan struct{}),
+	}
+	scheduler.wg.Add(2)
+	go func() {
+		defer scheduler.wg.Done()
+		scheduler.queue.Poll(scheduler.exitC, scheduler.nowF)
+	}()
+	go func() {
+		defer scheduler.wg.Done()
+		scheduler.run()
+	}()
+	return scheduler
+}
+
+// AfterFunc d之后执行一次fn
+func (scheduler *Scheduler) AfterFunc(d time.Duration, fn func()) *Task {
+	return scheduler.schedule(&Task{fn: fn}, d)
+}
+
+// Every 每隔d执行一次fn，按固定频率调度，不受fn执行时间的影响
+func (scheduler *Scheduler) Every(d time.Duration, fn func()) *Task {
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return scheduler.schedule(&Task{fn: fn, period: d.Milliseconds()}, d)
+}
+
+// Stop 停止调度器，未到期的任务不会再执行，不等待执行中的任务
+func (scheduler *Scheduler) Stop() {
+	scheduler.once.Do(func() {
+		close(scheduler.exitC)
+		scheduler.wg.Wait()
+	})
+}
+
+func (scheduler *Scheduler) schedule(task *Task, d time.Duration) *Task {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	task.next = scheduler.nowF() + d.Milliseconds()
+	// 队列不限制容量，Offer不会失败
+	task.item, _ = scheduler.queue.Offer(task, task.next)
+	return task
+}
+
+func (scheduler *Scheduler) run() {
+	for {
+		select {
+		case task := <-scheduler.queue.C:
+			scheduler.execute(task)
+		case <-scheduler.exitC:
+			return
+		}
+	}
+}
+
+func (scheduler *Scheduler) execute(task *Task) {
+	task.mu.Lock()
+	if task.stopped {
+		task.mu.Unlock()
+		return
+	}
+	if task.period > 0 {
+		task.next += task.period
+		_ = task.item.Reset(task.next)
+	} else {
+		task.started = true
+	}
+	task.mu.Unlock()
+
+	if scheduler.worker == nil {
+		go task.fn()
+		return
+	}
+	scheduler.worker.Schedule(task.fn)
+}
+
+// Stop 取消任务，任务已经开始执行或者已取消时返回false。
+// 已经从队列中取出但还未执行的任务同样会被取消
+func (task *Task) Stop() bool {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	if task.stopped {
+		return false
+	}
+	task.stopped = true
+	task.item.Cancel()
+	return task.period > 0 || !task.started
+}
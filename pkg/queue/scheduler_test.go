@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"linker/pkg/pool"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerAfterFunc(t *testing.T) {
+	scheduler := NewScheduler(pool.NewWorkerPool(4))
+	defer scheduler.Stop()
+
+	fired := make(chan string, 2)
+	scheduler.AfterFunc(30*time.Millisecond, func() { fired <- "b" })
+	scheduler.AfterFunc(10*time.Millisecond, func() { fired <- "a" })
+	canceled := scheduler.AfterFunc(20*time.Millisecond, func() { fired <- "canceled" })
+	assert.True(t, canceled.Stop())
+	assert.False(t, canceled.Stop())
+
+	assert.Equal(t, "a", <-fired)
+	assert.Equal(t, "b", <-fired)
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	defer scheduler.Stop()
+
+	var count int32
+	task := scheduler.Every(10*time.Millisecond, func() {
+		atomic.AddInt32(&count, 1)
+	})
+	time.Sleep(55 * time.Millisecond)
+	assert.True(t, task.Stop())
+	time.Sleep(5 * time.Millisecond) // 等待执行中的任务
+	n := atomic.LoadInt32(&count)
+	assert.GreaterOrEqual(t, n, int32(3))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(&count))
+}
+
+func TestSchedulerStopDelivered(t *testing.T) {
+	scheduler := &Scheduler{queue: NewDelayQueue[*Task](1)}
+	var fired int32
+	task := &Task{fn: func() { atomic.AddInt32(&fired, 1) }}
+	task.item, _ = scheduler.queue.Offer(task, 0)
+	// 任务已经从队列中取出交给run，但还未执行
+	item, _ := scheduler.queue.pq.peekAndShift(1)
+	assert.Equal(t, task, item.value)
+
+	assert.True(t, task.Stop())
+	scheduler.execute(task)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fired))
+	assert.False(t, task.Stop())
+
+	// 已经开始执行的任务返回false
+	task = &Task{fn: func() {}}
+	task.item, _ = scheduler.queue.Offer(task, 0)
+	_, _ = scheduler.queue.pq.peekAndShift(1)
+	scheduler.execute(task)
+	assert.False(t, task.Stop())
+}
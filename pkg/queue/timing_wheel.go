@@ -0,0 +1,220 @@
+package queue
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TimingWheel 分层时间轮，添加和取消任务都是O(1)
+//
+// 第0层每个槽位的跨度为tick，共wheelSize个槽位，上层每个槽位的跨度为下层整个时间轮的跨度，
+// 超出当前层范围的任务放入上层，上层槽位到期时将任务降级到下层
+type TimingWheel struct {
+	C chan interface{} // 通过Add添加的任务到期时从C中取出
+
+	tick      int64 // 纳秒
+	wheelSize int64
+
+	mu      sync.Mutex
+	wheel   *wheel
+	current int64 // 当前时间，按tick对齐
+
+	exitC chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// Timer 时间轮中的任务
+type Timer struct {
+	expiration int64
+	fn         func()
+	value      interface{}
+
+	tw      *TimingWheel
+	bucket  *list.List
+	element *list.Element
+}
+
+type wheel struct {
+	tick     int64 // 每个槽位的跨度
+	interval int64 // 整个时间轮的跨度
+	buckets  []*list.List
+	overflow *wheel // 上层时间轮，按需创建
+}
+
+func NewTimingWheel(tick time.Duration, wheelSize int) *TimingWheel {
+	if tick < time.Millisecond {
+		panic(errors.New("queue: tick must be greater than or equal to 1ms"))
+	}
+	if wheelSize <= 0 {
+		panic(errors.New("queue: wheel size must be greater than 0"))
+	}
+	tw := &TimingWheel{
+		C:         make(chan interface{}),
+		tick:      int64(tick),
+		wheelSize: int64(wheelSize),
+		exitC:     make(chan struct{}),
+	}
+	tw.current = truncate(time.Now().UnixNano(), tw.tick)
+	tw.wheel = newWheel(tw.tick, tw.wheelSize)
+	return tw
+}
+
+func newWheel(tick, size int64) *wheel {
+	w := &wheel{tick: tick, interval: tick * size, buckets: make([]*list.List, size)}
+	for i := range w.buckets {
+		w.buckets[i] = list.New()
+	}
+	return w
+}
+
+// Start 启动时间轮
+func (tw *TimingWheel) Start() {
+	tw.wg.Add(1)
+	go func() {
+		defer tw.wg.Done()
+		tw.run()
+	}()
+}
+
+// Stop 停止时间轮，未到期的任务不会再执行，可以重复调用
+func (tw *TimingWheel) Stop() {
+	tw.once.Do(func() {
+		close(tw.exitC)
+	})
+	tw.wg.Wait()
+}
+
+// AfterFunc d之后在时间轮的协程中执行fn，fn不能阻塞
+func (tw *TimingWheel) AfterFunc(d time.Duration, fn func()) *Timer {
+	return tw.schedule(&Timer{fn: fn}, d)
+}
+
+// Add d之后将value放入C
+func (tw *TimingWheel) Add(d time.Duration, value interface{}) *Timer {
+	return tw.schedule(&Timer{value: value}, d)
+}
+
+func (tw *TimingWheel) schedule(t *Timer, d time.Duration) *Timer {
+	t.tw = tw
+	// 到期时间向上对齐到tick，槽位在起始时刻执行，避免提前到期
+	t.expiration = truncate(time.Now().UnixNano()+int64(d)+tw.tick-1, tw.tick)
+	tw.mu.Lock()
+	if !tw.add(t) {
+		// 已到期的任务在下一个tick执行，避免阻塞调用方
+		bucket := tw.wheel.buckets[(tw.current/tw.tick+1)%tw.wheelSize]
+		t.bucket = bucket
+		t.element = bucket.PushBack(t)
+	}
+	tw.mu.Unlock()
+	return t
+}
+
+// Stop 取消任务，任务已到期或已取消时返回false
+func (t *Timer) Stop() bool {
+	t.tw.mu.Lock()
+	defer t.tw.mu.Unlock()
+	if t.bucket == nil {
+		return false
+	}
+	t.bucket.Remove(t.element)
+	t.bucket, t.element = nil, nil
+	return true
+}
+
+// add 将任务放入对应层级的槽位，已到期时返回false，调用时需持有锁
+func (tw *TimingWheel) add(t *Timer) bool {
+	w := tw.wheel
+	for {
+		current := truncate(tw.current, w.tick)
+		if t.expiration < current+w.tick {
+			return false
+		}
+		if t.expiration < current+w.interval {
+			bucket := w.buckets[(t.expiration/w.tick)%tw.wheelSize]
+			t.bucket = bucket
+			t.element = bucket.PushBack(t)
+			return true
+		}
+		if w.overflow == nil {
+			w.overflow = newWheel(w.interval, tw.wheelSize)
+		}
+		w = w.overflow
+	}
+}
+
+func (tw *TimingWheel) run() {
+	ticker := time.NewTicker(time.Duration(tw.tick))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			tw.advance(truncate(now.UnixNano(), tw.tick))
+		case <-tw.exitC:
+			return
+		}
+	}
+}
+
+// advance 逐个tick推进到now，先将上层到期槽位中的任务降级，再执行第0层到期的任务
+func (tw *TimingWheel) advance(now int64) {
+	for {
+		tw.mu.Lock()
+		if tw.current >= now {
+			tw.mu.Unlock()
+			return
+		}
+		tw.current += tw.tick
+		expired := make([]*Timer, 0)
+		for w := tw.wheel.overflow; w != nil; w = w.overflow {
+			if tw.current%w.tick != 0 {
+				break
+			}
+			for _, t := range tw.drain(w.buckets[(tw.current/w.tick)%tw.wheelSize]) {
+				if !tw.add(t) {
+					expired = append(expired, t)
+				}
+			}
+		}
+		expired = append(expired, tw.drain(tw.wheel.buckets[(tw.current/tw.tick)%tw.wheelSize])...)
+		tw.mu.Unlock()
+
+		for _, t := range expired {
+			if !tw.fire(t) {
+				return
+			}
+		}
+	}
+}
+
+// drain 取出槽位中的所有任务，调用时需持有锁
+func (tw *TimingWheel) drain(bucket *list.List) []*Timer {
+	timers := make([]*Timer, 0, bucket.Len())
+	for e := bucket.Front(); e != nil; e = e.Next() {
+		t := e.Value.(*Timer)
+		t.bucket, t.element = nil, nil
+		timers = append(timers, t)
+	}
+	bucket.Init()
+	return timers
+}
+
+// fire 执行到期的任务，时间轮已停止时返回false
+func (tw *TimingWheel) fire(t *Timer) bool {
+	if t.fn != nil {
+		t.fn()
+		return true
+	}
+	select {
+	case tw.C <- t.value:
+		return true
+	case <-tw.exitC:
+		return false
+	}
+}
+
+func truncate(x, m int64) int64 {
+	return x - x%m
+}
@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimingWheelAfterFunc(t *testing.T) {
+	tw := NewTimingWheel(time.Millisecond, 8)
+	tw.Start()
+	defer tw.Stop()
+
+	// 超出第0层范围的任务由上层时间轮降级执行
+	for _, d := range []time.Duration{5 * time.Millisecond, 30 * time.Millisecond, 100 * time.Millisecond} {
+		start := time.Now()
+		fired := make(chan time.Duration, 1)
+		tw.AfterFunc(d, func() {
+			fired <- time.Since(start)
+		})
+		select {
+		case elapsed := <-fired:
+			assert.GreaterOrEqual(t, elapsed, d)
+			assert.Less(t, elapsed, d+50*time.Millisecond)
+		case <-time.After(time.Second):
+			t.Fatalf("timer %s not fired", d)
+		}
+	}
+}
+
+func TestTimingWheelChannel(t *testing.T) {
+	tw := NewTimingWheel(time.Millisecond, 16)
+	tw.Start()
+	defer tw.Stop()
+
+	tw.Add(20*time.Millisecond, "b")
+	tw.Add(10*time.Millisecond, "a")
+	tw.Add(-time.Millisecond, "expired")
+	assert.Equal(t, "expired", <-tw.C)
+	assert.Equal(t, "a", <-tw.C)
+	assert.Equal(t, "b", <-tw.C)
+}
+
+func TestTimingWheelStop(t *testing.T) {
+	tw := NewTimingWheel(time.Millisecond, 4)
+	tw.Start()
+	defer tw.Stop()
+
+	var fired int32
+	timer := tw.AfterFunc(50*time.Millisecond, func() {
+		atomic.AddInt32(&fired, 1)
+	})
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+
+	done := make(chan struct{})
+	tw.AfterFunc(60*time.Millisecond, func() {
+		close(done)
+	})
+	<-done
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fired))
+
+	// 重复调用Stop不会panic
+	tw.Stop()
+}
@@ -0,0 +1,273 @@
+package queue
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"linker/pkg/binary"
+	"os"
+)
+
+// Serializer 持久化模式下任务的序列化方式
+type Serializer[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONSerializer 以JSON序列化任务
+type JSONSerializer[T any] struct{}
+
+func (JSONSerializer[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONSerializer[T]) Unmarshal(data []byte) (value T, err error) {
+	err = json.Unmarshal(data, &value)
+	return
+}
+
+var (
+	ErrCorruptedWAL = errors.New("queue: corrupted write-ahead log")
+	ErrTaskTooLarge = errors.New("queue: task exceeds the write-ahead log record limit")
+	// errTornRecord 日志末尾只写入了部分的记录
+	errTornRecord = errors.New("queue: torn write-ahead log record")
+)
+
+const (
+	walOffer  byte = 1
+	walRemove byte = 2
+
+	// 记录格式: op(1) id(8) expiration(8) length(4) 帧头crc32(4) payload crc32(4)。
+	// 帧头单独校验，长度字段在使用前已经可信
+	walHeaderSize = 25
+	walCRCSize    = 4
+
+	// 单条记录的最大长度，避免损坏的长度字段导致分配过多内存
+	walMaxRecordSize = 64 << 20
+
+	// 记录数超过该值且超过存活任务数的两倍时压缩日志
+	walCompactThreshold = 1024
+)
+
+// NewDurableDelayQueue 创建持久化的延时队列，任务的加入和移除追加到path指向的预写日志，
+// 启动时从日志中恢复未送出的任务。日志只写入操作系统缓存，进程重启不会丢失任务，
+// 已送出但未记录移除的任务在恢复后会再次送出
+func NewDurableDelayQueue[T any](size int, path string, serializer Serializer[T]) (*DelayQueue[T], error) {
+	queue := NewDelayQueue[T](size)
+	wal := &walJournal[T]{path: path, serializer: serializer, queue: queue, nextID: 1}
+	if err := wal.recover(); err != nil {
+		return nil, err
+	}
+	queue.journal = wal
+	return queue, nil
+}
+
+type walJournal[T any] struct {
+	path       string
+	file       *os.File
+	serializer Serializer[T]
+	queue      *DelayQueue[T]
+	nextID     uint64
+	records    int // 日志中的记录数
+}
+
+type walEntry struct {
+	expiration int64
+	payload    []byte
+}
+
+// recover 读取日志并重建堆，截断末尾不完整的记录，日志中间的记录损坏时返回ErrCorruptedWAL
+func (wal *walJournal[T]) recover() error {
+	file, err := os.OpenFile(wal.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	wal.file = file
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	entries := make(map[uint64]walEntry)
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		op, id, entry, n, err := readWALRecord(reader, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err == errTornRecord {
+			// 进程退出时可能只写入了部分记录，丢弃之后的数据
+			if err = file.Truncate(offset); err != nil {
+				_ = file.Close()
+				return err
+			}
+			break
+		}
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("%w at offset %d", err, offset)
+		}
+		offset += int64(n)
+		wal.records++
+		if id >= wal.nextID {
+			wal.nextID = id + 1
+		}
+		switch op {
+		case walOffer:
+			entries[id] = entry
+		case walRemove:
+			delete(entries, id)
+		}
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	for id, entry := range entries {
+		value, err := wal.serializer.Unmarshal(entry.payload)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		heap.Push(&wal.queue.pq, &Item[T]{value: value, priority: entry.expiration, queue: wal.queue, id: id})
+	}
+	return wal.compact()
+}
+
+// readWALRecord 读取一条记录，remaining为文件中剩余的字节数。
+// 帧头校验失败时返回ErrCorruptedWAL，只有延伸到文件末尾的记录才视为不完整的写入
+func readWALRecord(reader *bufio.Reader, remaining int64) (op byte, id uint64, entry walEntry, n int, err error) {
+	header := make([]byte, walHeaderSize)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errTornRecord
+		}
+		return
+	}
+	if crc32.ChecksumIEEE(header[:walHeaderSize-walCRCSize]) != uint32(binary.BigEndian.Int32(header[walHeaderSize-walCRCSize:])) {
+		err = ErrCorruptedWAL
+		return
+	}
+	size := int64(walHeaderSize) + int64(uint32(binary.BigEndian.Int32(header[17:]))) + walCRCSize
+	if size > walMaxRecordSize {
+		err = ErrCorruptedWAL
+		return
+	}
+	if size > remaining {
+		err = errTornRecord
+		return
+	}
+	length := int(size) - walHeaderSize - walCRCSize
+	body := make([]byte, length+walCRCSize)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		err = errTornRecord
+		return
+	}
+	if crc32.ChecksumIEEE(body[:length]) != uint32(binary.BigEndian.Int32(body[length:])) {
+		if size == remaining {
+			err = errTornRecord
+		} else {
+			err = ErrCorruptedWAL
+		}
+		return
+	}
+
+	op = header[0]
+	if op != walOffer && op != walRemove {
+		err = ErrCorruptedWAL
+		return
+	}
+	id = uint64(binary.BigEndian.Int64(header[1:]))
+	entry = walEntry{expiration: binary.BigEndian.Int64(header[9:]), payload: body[:length]}
+	return op, id, entry, walHeaderSize + length + walCRCSize, nil
+}
+
+func encodeWALRecord(op byte, id uint64, expiration int64, payload []byte) []byte {
+	record := make([]byte, walHeaderSize+len(payload)+walCRCSize)
+	record[0] = op
+	binary.BigEndian.PutInt64(record[1:], int64(id))
+	binary.BigEndian.PutInt64(record[9:], expiration)
+	binary.BigEndian.PutInt32(record[17:], int32(len(payload)))
+	binary.BigEndian.PutInt32(record[21:], int32(crc32.ChecksumIEEE(record[:21])))
+	copy(record[walHeaderSize:], payload)
+	binary.BigEndian.PutInt32(record[walHeaderSize+len(payload):], int32(crc32.ChecksumIEEE(payload)))
+	return record
+}
+
+func (wal *walJournal[T]) offer(item *Item[T], expiration int64) error {
+	payload, err := wal.serializer.Marshal(item.value)
+	if err != nil {
+		return err
+	}
+	// 超过上限的记录在恢复时会被视为损坏，需要在写入前拒绝
+	if walHeaderSize+len(payload)+walCRCSize > walMaxRecordSize {
+		return ErrTaskTooLarge
+	}
+	if item.id == 0 {
+		item.id = wal.nextID
+		wal.nextID++
+	}
+	return wal.append(encodeWALRecord(walOffer, item.id, expiration, payload))
+}
+
+func (wal *walJournal[T]) remove(item *Item[T]) error {
+	return wal.append(encodeWALRecord(walRemove, item.id, 0, nil))
+}
+
+func (wal *walJournal[T]) append(record []byte) error {
+	if _, err := wal.file.Write(record); err != nil {
+		return err
+	}
+	wal.records++
+	if wal.records > walCompactThreshold && wal.records > 2*wal.queue.pq.Len() {
+		return wal.compact()
+	}
+	return nil
+}
+
+// compact 将堆中的任务写入新的日志文件并替换旧文件
+func (wal *walJournal[T]) compact() error {
+	tmp := wal.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, item := range wal.queue.pq {
+		payload, err := wal.serializer.Marshal(item.value)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if _, err = writer.Write(encodeWALRecord(walOffer, item.id, item.priority, payload)); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, wal.path)
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	_ = wal.file.Close()
+	wal.file = file
+	wal.records = len(wal.queue.pq)
+	return nil
+}
+
+func (wal *walJournal[T]) close() error {
+	return wal.file.Close()
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type notification struct {
+	User string
+	Text string
+}
+
+func TestDurableDelayQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	queue, err := NewDurableDelayQueue[notification](16, path, JSONSerializer[notification]{})
+	assert.Nil(t, err)
+	now := time.Now().UnixMilli()
+	_, _ = queue.Offer(notification{User: "a", Text: "hello"}, now+30)
+	b, _ := queue.Offer(notification{User: "b", Text: "bye"}, now+10)
+	c, _ := queue.Offer(notification{User: "c", Text: "canceled"}, now+20)
+	assert.Nil(t, b.Reset(now+40))
+	assert.True(t, c.Cancel())
+	assert.Nil(t, queue.Close())
+
+	// 进程退出时写入了部分记录
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.Nil(t, err)
+	_, _ = file.Write([]byte{walOffer, 0, 0, 0})
+	_ = file.Close()
+
+	queue, err = NewDurableDelayQueue[notification](16, path, JSONSerializer[notification]{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, queue.Len())
+
+	exitC := make(chan struct{})
+	go queue.Poll(exitC, func() int64 {
+		return time.Now().UnixMilli()
+	})
+	assert.Equal(t, notification{User: "a", Text: "hello"}, <-queue.C)
+	assert.Equal(t, notification{User: "b", Text: "bye"}, <-queue.C)
+	close(exitC)
+	assert.Nil(t, queue.Close())
+
+	// 已送出的任务不会恢复
+	queue, err = NewDurableDelayQueue[notification](16, path, JSONSerializer[notification]{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, queue.Len())
+	assert.Nil(t, queue.Close())
+}
+
+func TestDurableDelayQueueCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	queue, err := NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.Nil(t, err)
+	defer queue.Close()
+
+	expiration := time.Now().Add(time.Hour).UnixMilli()
+	keep, _ := queue.Offer(-1, expiration)
+	for i := 0; i < 3*walCompactThreshold; i++ {
+		item, err := queue.Offer(i, expiration)
+		assert.Nil(t, err)
+		item.Cancel()
+	}
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Less(t, info.Size(), int64(2*walCompactThreshold*(walHeaderSize+walCRCSize+4)))
+	assert.Nil(t, queue.Close())
+
+	queue, err = NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, queue.Len())
+	assert.Equal(t, keep.id, queue.pq[0].id)
+}
+
+func TestDurableDelayQueueCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	queue, err := NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.Nil(t, err)
+	expiration := time.Now().Add(time.Hour).UnixMilli()
+	for i := 0; i < 3; i++ {
+		_, _ = queue.Offer(i, expiration)
+	}
+	assert.Nil(t, queue.Close())
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	// 末尾只写入了部分的记录被截断
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.Nil(t, err)
+	torn := encodeWALRecord(walOffer, 100, expiration, []byte("1"))
+	_, _ = file.Write(torn[:len(torn)-2])
+	_ = file.Close()
+	queue, err = NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, queue.Len())
+	assert.Nil(t, queue.Close())
+
+	// 长度字段损坏时帧头校验失败，返回错误且不截断日志
+	corrupted := append([]byte(nil), data...)
+	corrupted[17] ^= 0x01
+	assert.Nil(t, os.WriteFile(path, corrupted, 0o644))
+	_, err = NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.ErrorIs(t, err, ErrCorruptedWAL)
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), info.Size())
+
+	// 日志中间的记录校验失败时返回错误，不丢弃之后的记录
+	corrupted = append([]byte(nil), data...)
+	corrupted[walHeaderSize] ^= 0xff
+	assert.Nil(t, os.WriteFile(path, corrupted, 0o644))
+	_, err = NewDurableDelayQueue[int](16, path, JSONSerializer[int]{})
+	assert.ErrorIs(t, err, ErrCorruptedWAL)
+	info, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), info.Size())
+}
+
+func TestDurableDelayQueueTooLarge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	queue, err := NewDurableDelayQueue[string](16, path, JSONSerializer[string]{})
+	assert.Nil(t, err)
+	expiration := time.Now().Add(time.Hour).UnixMilli()
+	_, err = queue.Offer(strings.Repeat("x", walMaxRecordSize), expiration)
+	assert.Equal(t, ErrTaskTooLarge, err)
+	assert.Equal(t, 0, queue.Len())
+	_, err = queue.Offer("small", expiration)
+	assert.Nil(t, err)
+	assert.Nil(t, queue.Close())
+
+	// 被拒绝的任务不会写入日志，队列可以正常恢复
+	queue, err = NewDurableDelayQueue[string](16, path, JSONSerializer[string]{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, queue.Len())
+	assert.Nil(t, queue.Close())
+}
@@ -1,25 +1,70 @@
 package linker
 
 import (
+	"bufio"
+	"context"
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"io"
 	"linker/pkg/poller"
 	"linker/pkg/pool"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
 type MainReactor struct {
 	*Engine
 	*EventHandler
 
-	poll poller.Poller
+	poll     poller.Poller
+	acceptor Acceptor
 
+	options  *options
 	children []*SubReactor
+	registry *registry
+	rooms    *rooms
+	idle     *idleChecker
+
+	state    int32          // 运行状态
+	done     chan struct{}  // 关闭时通知所有协程退出
+	stopped  chan struct{}  // Shutdown完成后关闭
+	pollers  sync.WaitGroup // 运行中的epoll协程
+	stopOnce sync.Once
+	stopErr  error
 }
 
+// drainInterval 关闭时等待连接可写的最长时间(毫秒)
+const drainInterval = 10
+
+const (
+	stateIdle int32 = iota
+	stateRunning
+	stateStopping
+)
+
+var (
+	ErrServerRunning = errors.New("linker: server is already running")
+	ErrServerStopped = errors.New("linker: server is stopped")
+)
+
+// Run 启动服务并阻塞直至Shutdown完成
 func (reactor *MainReactor) Run(protocol string, bind string) (err error) {
-	reactor.Use(reactor.EventHandler.HandleRequest)
+	if err = reactor.Start(protocol, bind); err != nil {
+		return
+	}
+	return reactor.Wait()
+}
+
+// Start 监听端口并启动事件循环，不会阻塞
+func (reactor *MainReactor) Start(protocol string, bind string) (err error) {
+	if !atomic.CompareAndSwapInt32(&reactor.state, stateIdle, stateRunning) {
+		if atomic.LoadInt32(&reactor.state) == stateRunning {
+			return ErrServerRunning
+		}
+		return ErrServerStopped
+	}
 
 	log.Printf("%s server listen: %s\n", protocol, bind)
 	var accept Acceptor
@@ -27,29 +72,187 @@ func (reactor *MainReactor) Run(protocol string, bind string) (err error) {
 	case TCP:
 		accept = NewTCPAcceptor(reactor.dispatcher)
 	case UDP:
-		accept = NewUDPAcceptor(reactor.dispatcher)
+		accept = NewUDPAcceptor(reactor.sessionDispatcher, reactor.packetDispatcher).
+			WithReusePort(reactor.options.reusePort).
+			WithIdleTimeout(reactor.options.sessionIdleTimeout)
 	case WS:
-
+		accept = NewWebsocketAcceptor(reactor.upgradeDispatcher)
+	default:
+		atomic.StoreInt32(&reactor.state, stateIdle)
+		return errors.Errorf("unsupported protocol: %s", protocol)
 	}
 
 	err = accept.Listen(bind)
 	if err != nil {
+		atomic.StoreInt32(&reactor.state, stateIdle)
 		return
 	}
+	reactor.acceptor = accept
 	reactor.run()
 	return
 }
 
+// Addr 返回监听的地址，未启动时返回nil
+func (reactor *MainReactor) Addr() net.Addr {
+	if reactor.acceptor == nil {
+		return nil
+	}
+	return reactor.acceptor.Addr()
+}
+
+// Wait 阻塞直至Shutdown完成
+func (reactor *MainReactor) Wait() error {
+	<-reactor.stopped
+	return reactor.stopErr
+}
+
+// Shutdown 停止接收新连接和读取数据，等待处理中的请求完成、发送队列发送完毕后关闭所有连接。
+// ctx到期时不再等待处理中的请求，直接关闭连接并返回ctx.Err()。
+// 未启动时调用会使之后的Start返回ErrServerStopped
+//
+//	go system.Shutdown(func() { _ = reactor.Shutdown(ctx) })
+func (reactor *MainReactor) Shutdown(ctx context.Context) error {
+	reactor.stopOnce.Do(func() {
+		if atomic.CompareAndSwapInt32(&reactor.state, stateIdle, stateStopping) {
+			close(reactor.done)
+			reactor.stopErr = reactor.poll.Close()
+			close(reactor.stopped)
+			return
+		}
+		atomic.StoreInt32(&reactor.state, stateStopping)
+		// Close返回后accept和握手协程已经退出，不会再有新的连接或者UDP请求
+		if err := reactor.acceptor.Close(); err != nil {
+			log.Printf("acceptor.Close() error(%v)", err)
+		}
+		close(reactor.done)
+		reactor.pollers.Wait()
+
+		// 等待worker pool中的请求处理完成，期间epoll协程已退出，由drain发送各连接的发送队列
+		handled := make(chan struct{})
+		go func() {
+			for _, sub := range reactor.children {
+				sub.workerPool.Wait()
+			}
+			close(handled)
+		}()
+		drained, abort := make(chan struct{}), make(chan struct{})
+		go func() {
+			reactor.drain(handled, abort)
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			reactor.stopErr = ctx.Err()
+			close(abort)
+		}
+
+		reactor.closeConnections()
+		select {
+		case <-handled:
+			if err := reactor.poll.Close(); err != nil && reactor.stopErr == nil {
+				reactor.stopErr = err
+			}
+		default:
+			// 仍有请求在处理，poll在worker pool退出后再关闭，避免写入已关闭或者被复用的fd
+			go func() {
+				<-handled
+				_ = reactor.poll.Close()
+			}()
+		}
+		close(reactor.stopped)
+	})
+	<-reactor.stopped
+	return reactor.stopErr
+}
+
+// drain 同步发送所有连接的发送队列，直到handled关闭且队列全部发送完毕，或者abort关闭
+func (reactor *MainReactor) drain(handled, abort <-chan struct{}) {
+	for {
+		// 先判断请求是否处理完，之后的发送队列中已经包含了所有响应
+		finished := false
+		select {
+		case <-handled:
+			finished = true
+		default:
+		}
+
+		pending := make([]unix.PollFd, 0)
+		for _, conn := range reactor.registry.snapshot() {
+			c, ok := conn.(pollConn)
+			if !ok || !c.buffered() {
+				continue
+			}
+			if err := c.flush(); err != nil {
+				log.Printf("conn(%s) flush error(%v)", c.ID(), err)
+				c.Close()
+				continue
+			}
+			if c.buffered() {
+				pending = append(pending, unix.PollFd{Fd: int32(c.FD()), Events: unix.POLLOUT})
+			}
+		}
+		if finished && len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-abort:
+			return
+		default:
+		}
+		// 等待任一连接可写，没有待发送的连接时等待请求处理完成
+		_, _ = unix.Poll(pending, drainInterval)
+	}
+}
+
+// closeConnections 关闭所有连接，触发断开事件
+func (reactor *MainReactor) closeConnections() {
+	for _, conn := range reactor.registry.snapshot() {
+		conn.Close()
+	}
+}
+
+// GetConn 根据Conn.ID()查找连接，不存在时返回nil
+func (reactor *MainReactor) GetConn(id string) Conn {
+	return reactor.registry.get(id)
+}
+
+// Range 遍历所有连接，fn返回false时停止。遍历的是调用时的副本，fn中可以关闭连接
+func (reactor *MainReactor) Range(fn func(conn Conn) bool) {
+	for _, conn := range reactor.registry.snapshot() {
+		if !fn(conn) {
+			return
+		}
+	}
+}
+
+// Count 返回当前的连接数
+func (reactor *MainReactor) Count() int {
+	return reactor.registry.count()
+}
+
+// Kick 关闭指定ID的连接，连接不存在时返回false
+func (reactor *MainReactor) Kick(id string) bool {
+	conn := reactor.registry.get(id)
+	if conn == nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
 func (reactor *MainReactor) init() {
 	var err error
 	reactor.poll, err = poller.CreateEpoll()
 	if err != nil {
 		panic(errors.WithMessage(err, "create poll"))
 	}
+	reactor.idle = newIdleChecker(reactor)
 	for i := range reactor.children {
 		reactor.children[i] = &SubReactor{
 			core:        reactor,
-			connections: make(map[int]Conn, 1024),
+			connections: make(map[int]pollConn, 1024),
 			fd:          make(chan int, 1000),     // 同时处理1000个连接
 			workerPool:  pool.NewWorkerPool(1024), // 允许同时处理1024个请求
 		}
@@ -57,26 +260,105 @@ func (reactor *MainReactor) init() {
 }
 
 func (reactor *MainReactor) dispatcher(conn net.Conn) {
-	c := newConn(conn)
+	if atomic.LoadInt32(&reactor.state) != stateRunning {
+		_ = conn.Close()
+		return
+	}
+	c := newConn(conn, reactor.options, reactor.poll)
 	sub := reactor.children[c.FD()%len(reactor.children)]
+	// 注册后连接可能立即被读协程关闭，回调需要在注册前设置
+	c.closedCallback = sub.Release
 	if err := sub.Register(c); err != nil {
+		c.closedCallback = nil
 		c.Close()
 		return
 	}
+}
+
+func (reactor *MainReactor) upgradeDispatcher(conn net.Conn, reader *bufio.Reader) {
+	if atomic.LoadInt32(&reactor.state) != stateRunning {
+		_ = conn.Close()
+		return
+	}
+	c := newWebsocketConn(conn, reader, reactor.options, reactor.poll)
+	// 握手时可能已经读取了客户端的数据帧，epoll不会再通知。
+	// 注册后inbound会被读协程访问，需要在注册前检查
+	pending := !c.inbound.IsEmpty()
+	sub := reactor.children[c.FD()%len(reactor.children)]
+	// 注册后连接可能立即被读协程关闭，回调需要在注册前设置
 	c.closedCallback = sub.Release
+	if err := sub.Register(c); err != nil {
+		c.closedCallback = nil
+		c.Close()
+		return
+	}
+	if pending {
+		sub.Offer(c.FD())
+	}
+}
+
+// sessionDispatcher UDP会话不经过epoll，只需要登记并触发连接事件
+func (reactor *MainReactor) sessionDispatcher(conn *UDPConn) {
+	conn.requests.codec = reactor.Engine.headerCodec
+	conn.onClosed(reactor.releaseSession)
+	reactor.registry.add(conn)
+	if reactor.idle != nil {
+		reactor.idle.add(conn)
+	}
+	reactor.HandleConnect(conn)
+}
+
+func (reactor *MainReactor) releaseSession(conn Conn) {
+	reactor.registry.remove(conn)
+	reactor.rooms.leaveAll(conn)
+	reactor.HandleDisconnect(conn)
+}
+
+func (reactor *MainReactor) packetDispatcher(conn Conn, packet []byte) {
+	ctx := reactor.Engine.newContext(conn, packet)
+	reactor.subReactorOf(conn).workerPool.Schedule(ctx.Run)
 }
 
 func (reactor *MainReactor) run() {
+	reactor.pollers.Add(len(reactor.children) + 1)
 	for _, sub := range reactor.children {
-		go sub.Polling(reactor.Engine.buildContext)
+		go func(sub *SubReactor) {
+			defer reactor.pollers.Done()
+			sub.Polling(reactor.Engine.buildContext)
+		}(sub)
+	}
+	go func() {
+		defer reactor.pollers.Done()
+		reactor.polling()
+	}()
+	if reactor.idle != nil {
+		reactor.pollers.Add(1)
+		go func() {
+			defer reactor.pollers.Done()
+			reactor.idle.run(reactor.done)
+		}()
 	}
+}
+
+func (reactor *MainReactor) polling() {
 	for {
-		read, err := reactor.poll.Wait()
+		select {
+		case <-reactor.done:
+			return
+		default:
+		}
+
+		read, write, err := reactor.poll.Wait()
 		if err != nil {
 			log.Println("unable to get active socket connection from epoll:", err)
 			continue
 		}
 
+		// 发送队列中的数据，写入是非阻塞的，直接在当前协程处理
+		for _, fd := range write {
+			reactor.flush(fd)
+		}
+
 		// 处理待读取数据的链接
 		for _, fd := range read {
 			reactor.chooseSubReactor(fd).Offer(fd)
@@ -85,20 +367,55 @@ func (reactor *MainReactor) run() {
 	}
 }
 
+func (reactor *MainReactor) flush(fd int) {
+	sub := reactor.chooseSubReactor(fd)
+	conn := sub.GetConn(fd)
+	if conn == nil {
+		return
+	}
+	if err := conn.flush(); err != nil {
+		log.Printf("conn(%s) flush error(%v)", conn.ID(), err)
+		// 关闭连接会触发断开事件，不能阻塞epoll
+		sub.workerPool.Schedule(conn.Close)
+	}
+}
+
+// handlePanic 处理请求时发生panic，按照配置关闭该连接
+func (reactor *MainReactor) handlePanic(ctx *Context, err *PanicError) {
+	conn := ctx.Conn()
+	reactor.HandlePanic(conn, err)
+	if reactor.options.closeOnPanic {
+		conn.Close()
+	}
+}
+
 func (reactor *MainReactor) chooseSubReactor(fd int) *SubReactor {
 	return reactor.children[fd%len(reactor.children)]
 }
 
+// subReactorOf 选择处理连接的SubReactor
+func (reactor *MainReactor) subReactorOf(conn Conn) *SubReactor {
+	return reactor.children[reactor.shardOf(conn)]
+}
+
+// shardOf 连接所属SubReactor的下标，UDP会话共享socket的fd，按远端地址的哈希分散
+func (reactor *MainReactor) shardOf(conn Conn) int {
+	if c, ok := conn.(*UDPConn); ok {
+		return int(c.hash % uint32(len(reactor.children)))
+	}
+	return conn.FD() % len(reactor.children)
+}
+
 type SubReactor struct {
 	core *MainReactor
 
 	rmu         sync.RWMutex
-	connections map[int]Conn
+	connections map[int]pollConn
 	fd          chan int
 	workerPool  pool.Worker
 }
 
-func (reactor *SubReactor) Register(conn Conn) error {
+func (reactor *SubReactor) Register(conn pollConn) error {
 	fd := conn.FD()
 	if err := reactor.core.poll.Add(fd); err != nil {
 		return err
@@ -106,12 +423,16 @@ func (reactor *SubReactor) Register(conn Conn) error {
 
 	reactor.rmu.Lock()
 	reactor.connections[fd] = conn
-	reactor.core.HandleConnect(conn)
 	reactor.rmu.Unlock()
+	reactor.core.registry.add(conn)
+	if reactor.core.idle != nil {
+		reactor.core.idle.add(conn)
+	}
+	reactor.core.HandleConnect(conn)
 	return nil
 }
 
-func (reactor *SubReactor) GetConn(fd int) Conn {
+func (reactor *SubReactor) GetConn(fd int) pollConn {
 	reactor.rmu.RLock()
 	conn := reactor.connections[fd]
 	reactor.rmu.RUnlock()
@@ -119,35 +440,77 @@ func (reactor *SubReactor) GetConn(fd int) Conn {
 }
 
 func (reactor *SubReactor) Release(conn Conn) {
+	reactor.core.registry.remove(conn)
+	reactor.core.rooms.leaveAll(conn)
 	reactor.core.HandleDisconnect(conn)
 	fd := conn.FD()
-	if err := reactor.core.poll.Remove(fd); err != nil {
-		return
+	_ = reactor.core.poll.Remove(fd)
+
+	// fd可能已被新连接复用，只删除自己
+	reactor.rmu.Lock()
+	if current, ok := reactor.connections[fd]; ok && Conn(current) == conn {
+		delete(reactor.connections, fd)
 	}
-	delete(reactor.connections, fd)
-	return
+	reactor.rmu.Unlock()
 }
 
-func (reactor *SubReactor) Polling(contextBuilder func(conn Conn) (*Context, error)) {
-	var (
-		ctx *Context
-		err error
-	)
+func (reactor *SubReactor) Polling(contextBuilder func(conn pollConn) (*Context, error)) {
+	// 达到单次处理上限后仍有缓存帧的连接，epoll不会再次通知，需要自行轮转处理
+	pending := make([]int, 0, 64)
 	for {
-		fd := <-reactor.fd
-		conn := reactor.GetConn(fd)
-		if conn == nil {
+		if len(pending) == 0 {
+			select {
+			case fd := <-reactor.fd:
+				if reactor.handle(fd, contextBuilder) {
+					pending = append(pending, fd)
+				}
+			case <-reactor.core.done:
+				return
+			}
 			continue
 		}
 
-		ctx, err = contextBuilder(conn)
+		fd := pending[0]
+		pending = pending[1:]
+		if reactor.handle(fd, contextBuilder) {
+			pending = append(pending, fd)
+		}
+		// 交替处理新的就绪事件，避免饥饿
+		select {
+		case fd = <-reactor.fd:
+			if reactor.handle(fd, contextBuilder) {
+				pending = append(pending, fd)
+			}
+		case <-reactor.core.done:
+			return
+		default:
+		}
+	}
+}
+
+// handle 处理连接中所有已缓存的帧，单次最多处理framesPerWakeup个，返回是否还有未处理的帧
+func (reactor *SubReactor) handle(fd int, contextBuilder func(conn pollConn) (*Context, error)) bool {
+	conn := reactor.GetConn(fd)
+	if conn == nil {
+		return false
+	}
+
+	for i := 0; i < reactor.core.options.framesPerWakeup; i++ {
+		ctx, err := contextBuilder(conn)
 		if err != nil {
+			if err != io.EOF {
+				log.Printf("conn(%s) read error(%v)", conn.ID(), err)
+			}
 			conn.Close()
-			continue
+			return false
+		}
+		if ctx == nil {
+			return false
 		}
 		// 读取数据不能放在协程里执行
 		reactor.workerPool.Schedule(ctx.Run)
 	}
+	return true
 }
 
 func (reactor *SubReactor) Offer(fd int) {
@@ -1,9 +1,13 @@
 package linker
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"io"
 	"linker/pkg/system"
 	"log"
 	"math/rand"
@@ -138,3 +142,135 @@ func BenchmarkClient(b *testing.B) {
 	}
 	select {}
 }
+
+func TestReactorShutdown(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	disconnected := make(chan struct{}, 1)
+	reactor.OnDisconnect(func(conn Conn) {
+		disconnected <- struct{}{}
+	})
+	received := make(chan struct{})
+	reactor.OnRequest(func(ctx *Context) {
+		close(received)
+		// 关闭时需要等待处理中的请求完成
+		time.Sleep(100 * time.Millisecond)
+		_ = ctx.Conn().Push([]byte("bye"))
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+	assert.Equal(t, ErrServerRunning, reactor.Start(TCP, bind))
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("hello\n"))
+	assert.Nil(t, err)
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, reactor.Shutdown(ctx))
+	assert.Nil(t, reactor.Wait())
+	assert.Equal(t, ErrServerStopped, reactor.Start(TCP, bind))
+
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(c).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "bye\n", line)
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("disconnect event not fired")
+	}
+
+	_, err = net.DialTimeout("tcp", bind, time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestReactorShutdownBeforeStart(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	assert.Nil(t, reactor.Shutdown(context.Background()))
+	assert.Nil(t, reactor.Wait())
+	assert.Equal(t, ErrServerStopped, reactor.Start(TCP, "127.0.0.1:0"))
+}
+
+func TestReactorShutdownTimeout(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	received := make(chan Conn, 1)
+	release := make(chan struct{})
+	pushed := make(chan error, 1)
+	reactor.OnRequest(func(ctx *Context) {
+		received <- ctx.Conn()
+		<-release
+		pushed <- ctx.Conn().Push([]byte("late"))
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	_, _ = c.Write([]byte("hello\n"))
+	<-received
+
+	// 处理中的请求在关闭后写入，连接已关闭但poll仍然有效
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, reactor.Shutdown(ctx))
+	assert.Equal(t, context.DeadlineExceeded, reactor.Wait())
+	close(release)
+	assert.Equal(t, ErrConnClosed, <-pushed)
+}
+
+func TestReactorShutdownFlush(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	received := make(chan struct{})
+	payload := bytes.Repeat([]byte("x"), 1<<20)
+	reactor.OnRequest(func(ctx *Context) {
+		close(received)
+		time.Sleep(50 * time.Millisecond)
+		// 超出socket缓冲区的部分在关闭期间继续发送
+		assert.Nil(t, ctx.Conn().Push(payload))
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	_, _ = c.Write([]byte("hello\n"))
+	<-received
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- reactor.Shutdown(ctx)
+	}()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply := make([]byte, len(payload)+1)
+	_, err = io.ReadFull(c, reply)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, reply[:len(payload)])
+	assert.Nil(t, <-stopped)
+}
+
+func TestAcceptorCloseHandshake(t *testing.T) {
+	acceptor := NewWebsocketAcceptor(func(conn net.Conn, reader *bufio.Reader) {
+		t.Error("handshake completed after Close")
+	})
+	assert.Nil(t, acceptor.Listen("127.0.0.1:0"))
+	c, err := net.DialTimeout("tcp", acceptor.Addr().String(), time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	// Close中断进行中的握手并等待握手协程退出，不会等到握手超时
+	start := time.Now()
+	assert.Nil(t, acceptor.Close())
+	assert.Less(t, time.Since(start), time.Second)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = c.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
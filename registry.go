@@ -0,0 +1,84 @@
+package linker
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const registryShards = 32
+
+// registry 以Conn.ID()为键保存所有连接，分片加锁以减少连接和断开时的竞争
+type registry struct {
+	shards [registryShards]*registryShard
+}
+
+type registryShard struct {
+	mu          sync.RWMutex
+	connections map[string]Conn
+}
+
+func newRegistry() *registry {
+	r := &registry{}
+	for i := range r.shards {
+		r.shards[i] = &registryShard{connections: make(map[string]Conn, 64)}
+	}
+	return r
+}
+
+func (r *registry) shard(id string) *registryShard {
+	return r.shards[shardIndex(id, registryShards)]
+}
+
+// shardIndex 根据key的哈希值选择分片
+func shardIndex(key string, n uint32) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % n
+}
+
+func (r *registry) add(conn Conn) {
+	shard := r.shard(conn.ID())
+	shard.mu.Lock()
+	shard.connections[conn.ID()] = conn
+	shard.mu.Unlock()
+}
+
+func (r *registry) remove(conn Conn) {
+	shard := r.shard(conn.ID())
+	shard.mu.Lock()
+	if shard.connections[conn.ID()] == conn {
+		delete(shard.connections, conn.ID())
+	}
+	shard.mu.Unlock()
+}
+
+func (r *registry) get(id string) Conn {
+	shard := r.shard(id)
+	shard.mu.RLock()
+	conn := shard.connections[id]
+	shard.mu.RUnlock()
+	return conn
+}
+
+// snapshot 返回当前所有连接的副本，遍历副本时可以安全地关闭连接
+func (r *registry) snapshot() []Conn {
+	connections := make([]Conn, 0, r.count())
+	for _, shard := range r.shards {
+		shard.mu.RLock()
+		for _, conn := range shard.connections {
+			connections = append(connections, conn)
+		}
+		shard.mu.RUnlock()
+	}
+	return connections
+}
+
+func (r *registry) count() int {
+	n := 0
+	for _, shard := range r.shards {
+		shard.mu.RLock()
+		n += len(shard.connections)
+		shard.mu.RUnlock()
+	}
+	return n
+}
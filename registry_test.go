@@ -0,0 +1,37 @@
+package linker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.add(&UDPConn{uuid: strconv.Itoa(i)})
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 100, r.count())
+	assert.Len(t, r.snapshot(), 100)
+
+	conn := r.get("42")
+	assert.NotNil(t, conn)
+	assert.Equal(t, "42", conn.ID())
+
+	// 同一ID的新连接不会被旧连接的释放移除
+	replaced := &UDPConn{uuid: "42"}
+	r.add(replaced)
+	r.remove(conn)
+	assert.Equal(t, replaced, r.get("42"))
+
+	r.remove(replaced)
+	assert.Nil(t, r.get("42"))
+	assert.Equal(t, 99, r.count())
+}
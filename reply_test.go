@@ -0,0 +1,57 @@
+package linker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestContextReply(t *testing.T) {
+	codec := NewLengthPrefixCodec().WithCommandField(0, 2).WithSequenceField(2, 4)
+	reactor := NewReactor(WithProcessor(2), WithCodec(codec))
+	reactor.Handle(1, func(ctx *Context) {
+		// 第一个请求处理得更慢，响应乱序返回
+		if ctx.Sequence() == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_ = ctx.Reply(append([]byte("re:"), ctx.Body()...))
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	conn, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+	for sequence, body := range map[uint32]string{1: "slow", 2: "fast"} {
+		msg, _ := codec.EncodeHeader(Header{Route: CommandRoute(1), Sequence: sequence}, []byte(body))
+		frame, _ := codec.Encode(msg)
+		_, _ = conn.Write(frame)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	replies := make(map[uint32]string)
+	var order []uint32
+	in := make([]byte, 0, 64)
+	buf := make([]byte, 64)
+	for len(order) < 2 {
+		n, err := conn.Read(buf)
+		if !assert.Nil(t, err) {
+			return
+		}
+		in = append(in, buf[:n]...)
+		for len(in) >= 4 {
+			length := 4 + int(in[3])
+			if len(in) < length {
+				break
+			}
+			header, body, err := codec.DecodeHeader(in[4:length])
+			assert.Nil(t, err)
+			replies[header.Sequence] = string(body)
+			order = append(order, header.Sequence)
+			in = in[length:]
+		}
+	}
+	assert.Equal(t, map[uint32]string{1: "re:slow", 2: "re:fast"}, replies)
+	assert.Equal(t, []uint32{2, 1}, order)
+}
@@ -0,0 +1,112 @@
+package linker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrNoSequence      = errors.New("linker: codec does not carry sequence ids")
+	ErrTooManyRequests = errors.New("linker: too many pending requests")
+)
+
+// responder 支持服务端请求的连接
+type responder interface {
+	resolve(sequence uint32, body []byte) bool
+}
+
+// requests 连接上等待客户端响应的服务端请求，零值可用
+type requests struct {
+	mu      sync.Mutex
+	codec   HeaderCodec
+	next    uint32
+	waiting map[uint32]chan []byte
+	closed  bool
+}
+
+// do 以新的序列号发送请求并等待响应，ctx结束或者连接关闭时返回错误
+func (r *requests) do(ctx context.Context, conn Conn, msg []byte) ([]byte, error) {
+	if r.codec == nil || r.codec.MaxSequence() == 0 {
+		return nil, ErrNoSequence
+	}
+	sequence, reply, err := r.register()
+	if err != nil {
+		return nil, err
+	}
+	defer r.remove(sequence, reply)
+
+	if msg, err = r.codec.EncodeHeader(Header{Sequence: sequence}, msg); err != nil {
+		return nil, err
+	}
+	if err = conn.Push(msg); err != nil {
+		return nil, err
+	}
+	select {
+	case body, ok := <-reply:
+		if !ok {
+			return nil, ErrConnClosed
+		}
+		return body, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// register 分配一个未被占用的序列号，序列号在1到MaxSequence之间循环
+func (r *requests) register() (uint32, chan []byte, error) {
+	max := r.codec.MaxSequence()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, nil, ErrConnClosed
+	}
+	if r.waiting == nil {
+		r.waiting = make(map[uint32]chan []byte)
+	}
+	if uint64(len(r.waiting)) >= uint64(max) {
+		return 0, nil, ErrTooManyRequests
+	}
+	for {
+		r.next = r.next%max + 1
+		if _, ok := r.waiting[r.next]; !ok {
+			break
+		}
+	}
+	reply := make(chan []byte, 1)
+	r.waiting[r.next] = reply
+	return r.next, reply, nil
+}
+
+// remove 请求结束后释放序列号，序列号可能已经被新的请求占用
+func (r *requests) remove(sequence uint32, reply chan []byte) {
+	r.mu.Lock()
+	if r.waiting[sequence] == reply {
+		delete(r.waiting, sequence)
+	}
+	r.mu.Unlock()
+}
+
+// resolve 将响应交给等待中的请求，请求已超时或者不存在时返回false
+func (r *requests) resolve(sequence uint32, body []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reply, ok := r.waiting[sequence]
+	if !ok {
+		return false
+	}
+	delete(r.waiting, sequence)
+	reply <- body
+	return true
+}
+
+// close 连接关闭时唤醒所有等待中的请求
+func (r *requests) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for sequence, reply := range r.waiting {
+		close(reply)
+		delete(r.waiting, sequence)
+	}
+}
@@ -0,0 +1,96 @@
+package linker
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type requestResult struct {
+	reply []byte
+	err   error
+}
+
+func TestConnRequest(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2), WithCodec(NewLineCodec().WithSequence()))
+	var requested int32
+	reactor.OnRequest(func(ctx *Context) {
+		atomic.AddInt32(&requested, 1)
+	})
+	results := make(chan requestResult, 3)
+	reactor.OnConnect(func(conn Conn) {
+		go func() {
+			for _, timeout := range []time.Duration{time.Second, 100 * time.Millisecond, time.Second} {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				reply, err := conn.Request(ctx, []byte("confirm"))
+				cancel()
+				results <- requestResult{reply, err}
+			}
+		}()
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	conn, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// 第一个请求正常响应
+	line, err := reader.ReadString('\n')
+	assert.Nil(t, err)
+	sequence := strings.Fields(line)[0]
+	assert.Equal(t, sequence+" confirm\n", line)
+	_, _ = conn.Write([]byte("#" + sequence + " yes\n"))
+	result := <-results
+	assert.Nil(t, result.err)
+	assert.Equal(t, "yes", string(result.reply))
+
+	// 第二个请求超时，之后到达的响应被丢弃
+	line, _ = reader.ReadString('\n')
+	result = <-results
+	assert.Equal(t, context.DeadlineExceeded, result.err)
+	_, _ = conn.Write([]byte("#" + strings.Fields(line)[0] + " late\n"))
+
+	// 第三个请求等待时连接关闭
+	_, _ = reader.ReadString('\n')
+	_ = conn.Close()
+	select {
+	case result = <-results:
+		assert.Equal(t, ErrConnClosed, result.err)
+	case <-time.After(time.Second):
+		t.Fatal("request not released after close")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requested))
+}
+
+func TestRequestsSequence(t *testing.T) {
+	r := &requests{codec: NewLengthPrefixCodec().WithSequenceField(0, 1)}
+	assert.Equal(t, uint32(127), r.codec.MaxSequence())
+	for i := 1; i <= 127; i++ {
+		sequence, _, err := r.register()
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(i), sequence)
+	}
+	_, _, err := r.register()
+	assert.Equal(t, ErrTooManyRequests, err)
+
+	// 释放的序列号可以被重新分配
+	assert.True(t, r.resolve(5, nil))
+	sequence, _, err := r.register()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(5), sequence)
+
+	r.close()
+	_, _, err = r.register()
+	assert.Equal(t, ErrConnClosed, err)
+
+	_, err = (&requests{}).do(context.Background(), nil, nil)
+	assert.Equal(t, ErrNoSequence, err)
+}
@@ -0,0 +1,148 @@
+package linker
+
+import "sync"
+
+const roomShards = 256
+
+// rooms 房间和成员的双向索引，分别以房间名和Conn.ID()分片加锁
+type rooms struct {
+	rooms       [roomShards]*roomShard
+	memberships [roomShards]*membershipShard
+}
+
+type roomShard struct {
+	mu      sync.RWMutex
+	members map[string]map[string]Conn // room -> conn id -> conn
+}
+
+type membershipShard struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]struct{} // conn id -> rooms
+}
+
+func newRooms() *rooms {
+	r := &rooms{}
+	for i := 0; i < roomShards; i++ {
+		r.rooms[i] = &roomShard{members: make(map[string]map[string]Conn)}
+		r.memberships[i] = &membershipShard{rooms: make(map[string]map[string]struct{})}
+	}
+	return r
+}
+
+func (r *rooms) roomShard(room string) *roomShard {
+	return r.rooms[shardIndex(room, roomShards)]
+}
+
+func (r *rooms) membershipShard(id string) *membershipShard {
+	return r.memberships[shardIndex(id, roomShards)]
+}
+
+func (r *rooms) join(room string, conn Conn) {
+	id := conn.ID()
+	shard := r.roomShard(room)
+	shard.mu.Lock()
+	members := shard.members[room]
+	if members == nil {
+		members = make(map[string]Conn)
+		shard.members[room] = members
+	}
+	members[id] = conn
+	shard.mu.Unlock()
+
+	ms := r.membershipShard(id)
+	ms.mu.Lock()
+	joined := ms.rooms[id]
+	if joined == nil {
+		joined = make(map[string]struct{})
+		ms.rooms[id] = joined
+	}
+	joined[room] = struct{}{}
+	ms.mu.Unlock()
+}
+
+func (r *rooms) leave(room string, conn Conn) {
+	id := conn.ID()
+	ms := r.membershipShard(id)
+	ms.mu.Lock()
+	if joined := ms.rooms[id]; joined != nil {
+		delete(joined, room)
+		if len(joined) == 0 {
+			delete(ms.rooms, id)
+		}
+	}
+	ms.mu.Unlock()
+
+	r.removeMember(room, conn)
+}
+
+// leaveAll 连接断开时退出所有房间
+func (r *rooms) leaveAll(conn Conn) {
+	id := conn.ID()
+	ms := r.membershipShard(id)
+	ms.mu.Lock()
+	joined := ms.rooms[id]
+	delete(ms.rooms, id)
+	ms.mu.Unlock()
+
+	for room := range joined {
+		r.removeMember(room, conn)
+	}
+}
+
+func (r *rooms) removeMember(room string, conn Conn) {
+	id := conn.ID()
+	shard := r.roomShard(room)
+	shard.mu.Lock()
+	if members := shard.members[room]; members != nil && members[id] == conn {
+		delete(members, id)
+		if len(members) == 0 {
+			delete(shard.members, room)
+		}
+	}
+	shard.mu.Unlock()
+}
+
+// members 返回房间成员的副本
+func (r *rooms) members(room string) []Conn {
+	shard := r.roomShard(room)
+	shard.mu.RLock()
+	members := shard.members[room]
+	connections := make([]Conn, 0, len(members))
+	for _, conn := range members {
+		connections = append(connections, conn)
+	}
+	shard.mu.RUnlock()
+	return connections
+}
+
+func (r *rooms) count(room string) int {
+	shard := r.roomShard(room)
+	shard.mu.RLock()
+	n := len(shard.members[room])
+	shard.mu.RUnlock()
+	return n
+}
+
+// Join 将连接加入房间，连接断开时自动退出所有房间
+func (reactor *MainReactor) Join(room string, conn Conn) {
+	reactor.rooms.join(room, conn)
+	// 加入的同时连接可能已经断开并清理过房间，需要撤销
+	if reactor.registry.get(conn.ID()) != conn {
+		reactor.rooms.leave(room, conn)
+	}
+}
+
+// Leave 将连接移出房间
+func (reactor *MainReactor) Leave(room string, conn Conn) {
+	reactor.rooms.leave(room, conn)
+}
+
+// PublishToRoom 向房间内的所有成员发送消息，返回成功和被丢弃的数量
+func (reactor *MainReactor) PublishToRoom(room string, msg []byte) (delivered, dropped int, err error) {
+	return reactor.fanout(reactor.rooms.members(room), msg)
+}
+
+// RoomSize 返回房间的成员数量
+func (reactor *MainReactor) RoomSize(room string) int {
+	return reactor.rooms.count(room)
+}
@@ -0,0 +1,64 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRooms(t *testing.T) {
+	r := newRooms()
+	a, b := &UDPConn{uuid: "a"}, &UDPConn{uuid: "b"}
+	r.join("lobby", a)
+	r.join("lobby", b)
+	r.join("game", a)
+	assert.Equal(t, 2, r.count("lobby"))
+	assert.Equal(t, 1, r.count("game"))
+
+	r.leave("lobby", b)
+	assert.Equal(t, []Conn{a}, r.members("lobby"))
+
+	r.leaveAll(a)
+	assert.Equal(t, 0, r.count("lobby"))
+	assert.Equal(t, 0, r.count("game"))
+	assert.Empty(t, r.rooms[shardIndex("lobby", roomShards)].members)
+	assert.Empty(t, r.memberships[shardIndex("a", roomShards)].rooms)
+}
+
+func TestPublishToRoom(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	reactor.OnRequest(func(ctx *Context) {
+		reactor.Join(string(ctx.Body()), ctx.Conn())
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	clients := make([]net.Conn, 0, 3)
+	for _, room := range []string{"lobby", "lobby", "game"} {
+		c, err := net.DialTimeout("tcp", bind, time.Second)
+		assert.Nil(t, err)
+		defer c.Close()
+		_, _ = c.Write([]byte(room + "\n"))
+		clients = append(clients, c)
+	}
+	waitFor(func() bool { return reactor.RoomSize("lobby") == 2 && reactor.RoomSize("game") == 1 })
+	assert.Equal(t, 2, reactor.RoomSize("lobby"))
+
+	delivered, dropped, err := reactor.PublishToRoom("lobby", []byte("hi"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, 0, dropped)
+	for _, c := range clients[:2] {
+		_ = c.SetReadDeadline(time.Now().Add(time.Second))
+		line, err := bufio.NewReader(c).ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, "hi\n", line)
+	}
+
+	// 断开的连接自动退出房间
+	_ = clients[0].Close()
+	waitFor(func() bool { return reactor.RoomSize("lobby") == 1 })
+	assert.Equal(t, 1, reactor.RoomSize("lobby"))
+}
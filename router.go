@@ -0,0 +1,144 @@
+package linker
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type routeKind uint8
+
+const (
+	routeNone routeKind = iota
+	routeCommand
+	routeTopic
+)
+
+// Route 消息的路由键，由数字命令ID或字符串主题构成，零值表示没有路由
+type Route struct {
+	kind    routeKind
+	command uint32
+	topic   string
+}
+
+func CommandRoute(command uint32) Route {
+	return Route{kind: routeCommand, command: command}
+}
+
+func TopicRoute(topic string) Route {
+	return Route{kind: routeTopic, topic: topic}
+}
+
+// Command 返回命令ID，第二个返回值表示是否为命令路由
+func (route Route) Command() (uint32, bool) {
+	return route.command, route.kind == routeCommand
+}
+
+// Topic 返回主题，第二个返回值表示是否为主题路由
+func (route Route) Topic() (string, bool) {
+	return route.topic, route.kind == routeTopic
+}
+
+func (route Route) String() string {
+	switch route.kind {
+	case routeCommand:
+		return "command:" + strconv.FormatUint(uint64(route.command), 10)
+	case routeTopic:
+		return "topic:" + route.topic
+	default:
+		return "none"
+	}
+}
+
+// Router 按路由键分发消息，每个路由可以有自己的中间件，未匹配的消息交给NotFound处理
+// 路由需要在启动前注册
+type Router struct {
+	routes   map[Route][]HandleFunc
+	notFound []HandleFunc
+	check    func(middleware, route int) // 注册前检查处理链的长度
+}
+
+func newRouter() *Router {
+	return &Router{routes: make(map[Route][]HandleFunc)}
+}
+
+// Handle 注册命令ID的处理链，最后一个为处理函数，之前的为该路由的中间件
+func (router *Router) Handle(command uint32, handlers ...HandleFunc) {
+	router.add(CommandRoute(command), handlers)
+}
+
+// HandleTopic 注册主题的处理链，最后一个为处理函数，之前的为该路由的中间件
+func (router *Router) HandleTopic(topic string, handlers ...HandleFunc) {
+	router.add(TopicRoute(topic), handlers)
+}
+
+// NotFound 注册未匹配任何路由的消息的处理链，包括没有路由头或者路由头解析失败的消息
+func (router *Router) NotFound(handlers ...HandleFunc) {
+	router.checkChain(len(handlers))
+	router.notFound = handlers
+}
+
+// HasRoute 路由是否已注册
+func (router *Router) HasRoute(route Route) bool {
+	_, ok := router.routes[route]
+	return ok
+}
+
+func (router *Router) add(route Route, handlers []HandleFunc) {
+	if len(handlers) == 0 {
+		panic(fmt.Sprintf("linker: route %s has no handler", route))
+	}
+	if _, ok := router.routes[route]; ok {
+		panic(fmt.Sprintf("linker: route %s already registered", route))
+	}
+	router.checkChain(len(handlers))
+	router.routes[route] = handlers
+}
+
+func (router *Router) checkChain(route int) {
+	if router.check != nil {
+		router.check(0, route)
+	}
+}
+
+// longest 返回最长的路由处理链的长度
+func (router *Router) longest() int {
+	n := len(router.notFound)
+	for _, handlers := range router.routes {
+		if len(handlers) > n {
+			n = len(handlers)
+		}
+	}
+	return n
+}
+
+// match 返回路由对应的处理链
+func (router *Router) match(route Route) []HandleFunc {
+	if handlers, ok := router.routes[route]; ok {
+		return handlers
+	}
+	return router.notFound
+}
+
+// MatchCommand 用于Group，只对指定命令ID的消息生效
+func MatchCommand(commands ...uint32) func(ctx *Context) bool {
+	set := make(map[Route]struct{}, len(commands))
+	for _, command := range commands {
+		set[CommandRoute(command)] = struct{}{}
+	}
+	return func(ctx *Context) bool {
+		_, ok := set[ctx.header.Route]
+		return ok
+	}
+}
+
+// MatchTopic 用于Group，只对指定主题的消息生效
+func MatchTopic(topics ...string) func(ctx *Context) bool {
+	set := make(map[Route]struct{}, len(topics))
+	for _, topic := range topics {
+		set[TopicRoute(topic)] = struct{}{}
+	}
+	return func(ctx *Context) bool {
+		_, ok := set[ctx.header.Route]
+		return ok
+	}
+}
@@ -0,0 +1,105 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	codec := NewLengthPrefixCodec().WithCommandField(0, 2)
+	engine := newEngine(1)
+	engine.headerCodec = codec
+	var trace []string
+	engine.Group(MatchCommand(2), func(ctx *Context) {
+		trace = append(trace, "auth")
+	})
+	engine.Handle(1, func(ctx *Context) {
+		trace = append(trace, "login:"+string(ctx.Body()))
+	})
+	engine.Handle(2, func(ctx *Context) {
+		trace = append(trace, "route")
+		ctx.Next()
+	}, func(ctx *Context) {
+		trace = append(trace, "trade:"+string(ctx.Body()))
+	})
+	engine.NotFound(func(ctx *Context) {
+		trace = append(trace, "not found:"+ctx.Route().String())
+	})
+
+	for _, command := range []uint32{1, 2, 3} {
+		body, _ := codec.EncodeHeader(Header{Route: CommandRoute(command)}, []byte("x"))
+		engine.processContext(&Context{engine: engine, body: body})
+	}
+	assert.Equal(t, []string{"login:x", "auth", "route", "trade:x", "not found:command:3"}, trace)
+
+	// 路由头不完整
+	trace = nil
+	ctx := &Context{engine: engine, body: []byte{1}}
+	engine.processContext(ctx)
+	assert.Equal(t, []string{"not found:none"}, trace)
+	assert.Equal(t, []error{ErrFrameHeader}, ctx.Errors())
+}
+
+func TestRouterDuplicate(t *testing.T) {
+	router := newRouter()
+	router.HandleTopic("chat", func(ctx *Context) {})
+	assert.Panics(t, func() {
+		router.HandleTopic("chat", func(ctx *Context) {})
+	})
+	assert.Panics(t, func() {
+		router.Handle(1)
+	})
+}
+
+func TestTopicRoute(t *testing.T) {
+	codec := NewLineCodec().WithTopicSeparator(' ')
+	header, body, err := codec.DecodeHeader([]byte("chat hello world"))
+	assert.Nil(t, err)
+	topic, ok := header.Route.Topic()
+	assert.True(t, ok)
+	assert.Equal(t, "chat", topic)
+	assert.Equal(t, "hello world", string(body))
+
+	header, body, _ = codec.DecodeHeader([]byte("ping"))
+	assert.Equal(t, TopicRoute("ping"), header.Route)
+	assert.Empty(t, body)
+	encoded, _ := codec.EncodeHeader(Header{Route: TopicRoute("chat")}, []byte("hi"))
+	assert.Equal(t, "chat hi", string(encoded))
+
+	// 未开启主题路由时不解析
+	header, body, _ = NewLineCodec().DecodeHeader([]byte("chat hi"))
+	assert.Equal(t, Header{}, header)
+	assert.Equal(t, "chat hi", string(body))
+}
+
+func TestRouterTopic(t *testing.T) {
+	codec := NewLineCodec().WithTopicSeparator(' ')
+	reactor := NewReactor(WithProcessor(2), WithCodec(codec))
+	reactor.HandleTopic("echo", func(ctx *Context) {
+		_ = ctx.Reply(ctx.Body())
+	})
+	reactor.OnRequest(func(ctx *Context) {
+		_ = ctx.Conn().Push([]byte("unknown " + ctx.Route().String()))
+	})
+	bind, shutdown := startReactor(t, reactor, TCP)
+	defer shutdown()
+
+	conn, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, _ = conn.Write([]byte("echo hello\nquit now\n"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	reader := bufio.NewReader(conn)
+	lines := make([]string, 0, 2)
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+	assert.ElementsMatch(t, []string{"echo hello\n", "unknown topic:quit\n"}, lines)
+}
@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"linker"
+	"linker/pkg/buffer"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrShutdown = errors.New("rpc: connection is shut down")
+
+// ServerError 服务端方法返回的错误
+type ServerError string
+
+func (err ServerError) Error() string {
+	return string(err)
+}
+
+// Client 通过一个TCP连接并发调用服务端的方法，响应以序列号匹配
+type Client struct {
+	conn       net.Conn
+	codec      *Codec
+	serializer Serializer
+	timeout    time.Duration
+
+	wmu     sync.Mutex // 保证帧完整写入
+	mu      sync.Mutex // 保护以下字段
+	next    uint32
+	pending map[uint32]chan []byte
+	err     error // 连接断开的原因
+}
+
+// Dial 连接服务端，timeout同时作为调用的默认超时时间
+func Dial(addr string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn).WithTimeout(timeout), nil
+}
+
+// NewClient 在已建立的连接上创建客户端并开始读取响应
+func NewClient(conn net.Conn) *Client {
+	client := &Client{
+		conn:       conn,
+		codec:      NewCodec(),
+		serializer: JSONSerializer{},
+		pending:    make(map[uint32]chan []byte),
+	}
+	go client.receive()
+	return client
+}
+
+func (client *Client) WithSerializer(serializer Serializer) *Client {
+	client.serializer = serializer
+	return client
+}
+
+// WithTimeout ctx没有设置截止时间时调用的超时时间，0表示不限制
+func (client *Client) WithTimeout(timeout time.Duration) *Client {
+	client.timeout = timeout
+	return client
+}
+
+// Call 调用服务端的方法，method的格式为"Service.Method"，结果反序列化到reply中
+func (client *Client) Call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	if _, ok := ctx.Deadline(); !ok && client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.timeout)
+		defer cancel()
+	}
+
+	body, err := client.serializer.Marshal(args)
+	if err != nil {
+		return err
+	}
+	sequence, done, err := client.register()
+	if err != nil {
+		return err
+	}
+	defer client.remove(sequence, done)
+	if err = client.send(linker.Header{Route: linker.TopicRoute(method), Sequence: sequence}, body); err != nil {
+		return err
+	}
+
+	select {
+	case msg, ok := <-done:
+		if !ok {
+			return client.shutdownErr()
+		}
+		if len(msg) == 0 {
+			return linker.ErrFrameHeader
+		}
+		if msg[0] == statusError {
+			return ServerError(msg[1:])
+		}
+		return client.serializer.Unmarshal(msg[1:], reply)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 关闭连接，等待中的调用返回ErrShutdown
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+func (client *Client) register() (uint32, chan []byte, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.err != nil {
+		return 0, nil, ErrShutdown
+	}
+	for {
+		client.next = client.next%client.codec.MaxSequence() + 1
+		if _, ok := client.pending[client.next]; !ok {
+			break
+		}
+	}
+	done := make(chan []byte, 1)
+	client.pending[client.next] = done
+	return client.next, done, nil
+}
+
+func (client *Client) remove(sequence uint32, done chan []byte) {
+	client.mu.Lock()
+	if client.pending[sequence] == done {
+		delete(client.pending, sequence)
+	}
+	client.mu.Unlock()
+}
+
+func (client *Client) shutdownErr() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.err
+}
+
+func (client *Client) send(header linker.Header, body []byte) error {
+	msg, err := client.codec.EncodeHeader(header, body)
+	if err != nil {
+		return err
+	}
+	frame, err := client.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	client.wmu.Lock()
+	defer client.wmu.Unlock()
+	_, err = client.conn.Write(frame)
+	return err
+}
+
+// receive 读取响应并交给等待中的调用，连接断开时唤醒所有调用
+func (client *Client) receive() {
+	in := buffer.NewRingBuffer(4096)
+	buf := make([]byte, 4096)
+	var err error
+	for err == nil {
+		var n int
+		if n, err = client.conn.Read(buf); n > 0 {
+			_, _ = in.Write(buf[:n])
+		}
+		for err == nil {
+			var frame []byte
+			if frame, err = client.codec.Decode(in); err != nil || frame == nil {
+				break
+			}
+			client.dispatch(frame)
+		}
+	}
+	_ = client.conn.Close()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.err = ErrShutdown
+	for sequence, done := range client.pending {
+		close(done)
+		delete(client.pending, sequence)
+	}
+}
+
+// dispatch 服务端主动发起的请求不需要客户端处理，直接丢弃
+func (client *Client) dispatch(frame []byte) {
+	header, body, err := client.codec.DecodeHeader(frame)
+	if err != nil || !header.Response {
+		return
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if done, ok := client.pending[header.Sequence]; ok {
+		delete(client.pending, header.Sequence)
+		done <- body
+	}
+}
+
+// Stub 类型安全的客户端方法
+type Stub[A, R any] struct {
+	client *Client
+	method string
+}
+
+func NewStub[A, R any](client *Client, method string) *Stub[A, R] {
+	return &Stub[A, R]{client: client, method: method}
+}
+
+func (stub *Stub[A, R]) Call(ctx context.Context, args A) (reply R, err error) {
+	err = stub.client.Call(ctx, stub.method, args, &reply)
+	return
+}
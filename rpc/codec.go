@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"linker"
+	"linker/pkg/binary"
+	"math"
+)
+
+const (
+	// 帧头: 序列号(4) 方法名长度(1) 方法名
+	sequenceSize = 4
+	responseBit  = 1 << 31
+)
+
+// Codec RPC使用的编解码器，以4字节长度切分帧，帧内容为序列号、方法名和消息体，
+// 序列号的最高位表示响应。服务端需要通过linker.WithCodec使用该编解码器
+type Codec struct {
+	frame *linker.LengthFieldCodec
+}
+
+func NewCodec() *Codec {
+	return &Codec{frame: linker.NewLengthPrefixCodec()}
+}
+
+// WithMaxFrameLength 限制帧的最大长度
+func (codec *Codec) WithMaxFrameLength(length int) *Codec {
+	codec.frame.WithMaxFrameLength(length)
+	return codec
+}
+
+func (codec *Codec) FrameLength(in linker.InboundBuffer) (int, error) {
+	return codec.frame.FrameLength(in)
+}
+
+func (codec *Codec) Decode(in linker.InboundBuffer) ([]byte, error) {
+	return codec.frame.Decode(in)
+}
+
+func (codec *Codec) Encode(msg []byte) ([]byte, error) {
+	return codec.frame.Encode(msg)
+}
+
+func (codec *Codec) DecodeHeader(frame []byte) (header linker.Header, body []byte, err error) {
+	if len(frame) < sequenceSize+1 {
+		return linker.Header{}, nil, linker.ErrFrameHeader
+	}
+	value := uint32(binary.BigEndian.Int32(frame))
+	header.Sequence = value &^ responseBit
+	header.Response = value&responseBit != 0
+
+	end := sequenceSize + 1 + int(frame[sequenceSize])
+	if len(frame) < end {
+		return linker.Header{}, nil, linker.ErrFrameHeader
+	}
+	if end > sequenceSize+1 {
+		header.Route = linker.TopicRoute(string(frame[sequenceSize+1 : end]))
+	}
+	return header, frame[end:], nil
+}
+
+func (codec *Codec) EncodeHeader(header linker.Header, msg []byte) ([]byte, error) {
+	method, _ := header.Route.Topic()
+	if len(method) > math.MaxUint8 || header.Sequence > codec.MaxSequence() {
+		return nil, linker.ErrFrameHeader
+	}
+	value := header.Sequence
+	if header.Response {
+		value |= responseBit
+	}
+
+	out := make([]byte, sequenceSize+1+len(method)+len(msg))
+	binary.BigEndian.PutInt32(out, int32(value))
+	out[sequenceSize] = byte(len(method))
+	n := copy(out[sequenceSize+1:], method)
+	copy(out[sequenceSize+1+n:], msg)
+	return out, nil
+}
+
+func (codec *Codec) MaxSequence() uint32 {
+	return responseBit - 1
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"linker"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Arith struct{}
+
+func (Arith) Add(ctx *linker.Context, args *Args) (int, error) {
+	return args.A + args.B, nil
+}
+
+func (Arith) Div(ctx *linker.Context, args Args) (int, error) {
+	if args.B == 0 {
+		return 0, errors.New("divide by zero")
+	}
+	return args.A / args.B, nil
+}
+
+func (Arith) Slow(ctx *linker.Context, d time.Duration) (bool, error) {
+	time.Sleep(d)
+	return true, nil
+}
+
+// Secret 只允许通过认证中间件的调用
+func (Arith) Secret(ctx *linker.Context, args Args) (string, error) {
+	return "secret", nil
+}
+
+func (Arith) Panic(ctx *linker.Context, args Args) (int, error) {
+	panic("boom")
+}
+
+// Ignored 签名不符合要求的方法不会被注册
+func (Arith) Ignored(args Args) int {
+	return 0
+}
+
+// startLoop 在随机端口上启动loop，返回监听地址和关闭loop的函数
+func startLoop(t *testing.T, loop linker.EventLoop) (string, func()) {
+	if err := loop.Start(linker.TCP, "127.0.0.1:0"); err != nil {
+		t.Fatalf("loop.Start() error(%v)", err)
+	}
+	return loop.Addr().String(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = loop.Shutdown(ctx)
+	}
+}
+
+func TestRPC(t *testing.T) {
+	loop := linker.NewReactor(linker.WithProcessor(2), linker.WithCodec(NewCodec()))
+	var calls int32
+	var mu sync.Mutex
+	// 在NewServer之前注册的中间件终止调用时同样会响应
+	loop.Use(func(ctx *linker.Context) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	})
+	loop.Group(linker.MatchTopic("Arith.Secret"), func(ctx *linker.Context) {
+		ctx.AbortWithError(errors.New("unauthorized"))
+	})
+	panicked := make(chan struct{}, 1)
+	loop.OnPanic(func(conn linker.Conn, err *linker.PanicError) {
+		panicked <- struct{}{}
+	})
+	// 服务名前缀下的其他主题不受影响
+	loop.HandleTopic("Arith.events", func(ctx *linker.Context) {
+		_ = ctx.Reply(append([]byte{statusOK}, `"event"`...))
+	})
+	server := NewServer(loop)
+	assert.Nil(t, server.Register(Arith{}))
+	assert.NotNil(t, server.Register(Arith{}))
+	assert.Nil(t, RegisterFunc(server, "Echo.Upper", func(ctx *linker.Context, s string) (string, error) {
+		return fmt.Sprintf("<%s>", s), nil
+	}))
+	bind, shutdown := startLoop(t, loop)
+	defer shutdown()
+
+	client, err := Dial(bind, time.Second)
+	assert.Nil(t, err)
+	defer client.Close()
+	ctx := context.Background()
+
+	var sum int
+	assert.Nil(t, client.Call(ctx, "Arith.Add", Args{1, 2}, &sum))
+	assert.Equal(t, 3, sum)
+
+	err = client.Call(ctx, "Arith.Div", Args{1, 0}, &sum)
+	assert.Equal(t, ServerError("divide by zero"), err)
+	err = client.Call(ctx, "Arith.Ignored", Args{}, &sum)
+	assert.Equal(t, ServerError(ErrMethodNotFound.Error()), err)
+	err = client.Call(ctx, "Nope.Add", Args{}, &sum)
+	assert.Equal(t, ServerError(ErrMethodNotFound.Error()), err)
+	err = client.Call(ctx, "Arith.Secret", Args{}, new(string))
+	assert.Equal(t, ServerError("unauthorized"), err)
+	err = client.Call(ctx, "Arith.Panic", Args{}, &sum)
+	assert.Equal(t, ServerError(errPanic.Error()), err)
+	<-panicked
+	var event string
+	assert.Nil(t, client.Call(ctx, "Arith.events", nil, &event))
+	assert.Equal(t, "event", event)
+
+	upper, err := NewStub[string, string](client, "Echo.Upper").Call(ctx, "hi")
+	assert.Nil(t, err)
+	assert.Equal(t, "<hi>", upper)
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = client.Call(timeout, "Arith.Slow", 200*time.Millisecond, new(bool))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 并发调用的响应按序列号匹配
+	add := NewStub[*Args, int](client, "Arith.Add")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sum, err := add.Call(ctx, &Args{i, i})
+			assert.Nil(t, err)
+			assert.Equal(t, 2*i, sum)
+		}(i)
+	}
+	wg.Wait()
+	mu.Lock()
+	assert.Equal(t, int32(29), calls)
+	mu.Unlock()
+
+	_ = client.Close()
+	assert.Equal(t, ErrShutdown, client.Call(ctx, "Arith.Add", Args{}, &sum))
+}
+
+func TestCodec(t *testing.T) {
+	codec := NewCodec()
+	header := linker.Header{Route: linker.TopicRoute("Arith.Add"), Sequence: 7, Response: true}
+	msg, err := codec.EncodeHeader(header, []byte("{}"))
+	assert.Nil(t, err)
+	decoded, body, err := codec.DecodeHeader(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, header, decoded)
+	assert.Equal(t, "{}", string(body))
+
+	_, _, err = codec.DecodeHeader(msg[:6])
+	assert.Equal(t, linker.ErrFrameHeader, err)
+}
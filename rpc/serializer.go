@@ -0,0 +1,20 @@
+package rpc
+
+import "encoding/json"
+
+// Serializer 参数和返回值的序列化方式，客户端和服务端需要一致
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONSerializer 以JSON序列化，默认的序列化方式
+type JSONSerializer struct{}
+
+func (JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONSerializer) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"linker"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// 响应的第一个字节表示调用结果
+const (
+	statusOK    byte = 0
+	statusError byte = 1
+)
+
+var (
+	errAborted        = errors.New("rpc: call aborted by middleware")
+	errPanic          = errors.New("rpc: method panicked")
+	typeOfContext     = reflect.TypeOf((*linker.Context)(nil))
+	typeOfError       = reflect.TypeOf((*error)(nil)).Elem()
+	ErrMethodNotFound = errors.New("rpc: method not found")
+)
+
+// Server 将服务的方法以"Service.Method"为主题路由注册到EventLoop上，
+// 调用会经过EventLoop的全局中间件和分组中间件，可以通过linker.MatchTopic为部分方法添加中间件。
+// 被中间件终止的调用以终止原因响应，与中间件的注册顺序无关
+type Server struct {
+	loop       linker.EventLoop
+	serializer Serializer
+	services   map[string]struct{}
+	methods    map[string]*method
+}
+
+// method 一个可调用的方法，call反序列化参数并返回序列化后的结果
+type method struct {
+	call func(ctx *linker.Context, body []byte) ([]byte, error)
+}
+
+// NewServer loop需要使用NewCodec创建的编解码器
+func NewServer(loop linker.EventLoop) *Server {
+	server := &Server{
+		loop:       loop,
+		serializer: JSONSerializer{},
+		services:   make(map[string]struct{}),
+		methods:    make(map[string]*method),
+	}
+	loop.Group(server.missing, func(ctx *linker.Context) {
+		ctx.AbortWithError(ErrMethodNotFound)
+	})
+	loop.OnAbort(server.aborted)
+	return server
+}
+
+func (server *Server) WithSerializer(serializer Serializer) *Server {
+	server.serializer = serializer
+	return server
+}
+
+// Register 以svc的类型名作为服务名注册服务
+func (server *Server) Register(svc interface{}) error {
+	return server.RegisterName(reflect.Indirect(reflect.ValueOf(svc)).Type().Name(), svc)
+}
+
+// RegisterName 注册svc中所有形如 func(ctx *linker.Context, args A) (R, error) 的导出方法，
+// 不符合的方法会被忽略，需要在启动前调用
+func (server *Server) RegisterName(name string, svc interface{}) error {
+	if name == "" || strings.Contains(name, ".") {
+		return fmt.Errorf("rpc: invalid service name %q", name)
+	}
+	if _, ok := server.services[name]; ok {
+		return fmt.Errorf("rpc: service %s already registered", name)
+	}
+
+	value := reflect.ValueOf(svc)
+	methods := make(map[string]*method)
+	for i := 0; i < value.NumMethod(); i++ {
+		fn := value.Method(i)
+		if m := server.reflectMethod(fn); m != nil {
+			methods[value.Type().Method(i).Name] = m
+		}
+	}
+	if len(methods) == 0 {
+		return fmt.Errorf("rpc: service %s has no suitable method", name)
+	}
+
+	server.services[name] = struct{}{}
+	for methodName, m := range methods {
+		server.handle(name+"."+methodName, m)
+	}
+	return nil
+}
+
+// RegisterFunc 以泛型注册单个方法，name的格式为"Service.Method"
+func RegisterFunc[A, R any](server *Server, name string, fn func(ctx *linker.Context, args A) (R, error)) error {
+	service, _, ok := strings.Cut(name, ".")
+	if !ok || service == "" {
+		return fmt.Errorf("rpc: invalid method name %q", name)
+	}
+	if _, ok := server.methods[name]; ok {
+		return fmt.Errorf("rpc: method %s already registered", name)
+	}
+
+	server.services[service] = struct{}{}
+	server.handle(name, &method{call: func(ctx *linker.Context, body []byte) ([]byte, error) {
+		var args A
+		if err := server.serializer.Unmarshal(body, &args); err != nil {
+			return nil, err
+		}
+		reply, err := fn(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return server.serializer.Marshal(reply)
+	}})
+	return nil
+}
+
+// reflectMethod 检查方法的签名，不符合时返回nil
+func (server *Server) reflectMethod(fn reflect.Value) *method {
+	typ := fn.Type()
+	if typ.NumIn() != 2 || typ.In(0) != typeOfContext || typ.NumOut() != 2 || typ.Out(1) != typeOfError {
+		return nil
+	}
+	argType, isPtr := typ.In(1), false
+	if argType.Kind() == reflect.Ptr {
+		argType, isPtr = argType.Elem(), true
+	}
+
+	return &method{call: func(ctx *linker.Context, body []byte) ([]byte, error) {
+		args := reflect.New(argType)
+		if err := server.serializer.Unmarshal(body, args.Interface()); err != nil {
+			return nil, err
+		}
+		if !isPtr {
+			args = args.Elem()
+		}
+		out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), args})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		return server.serializer.Marshal(out[0].Interface())
+	}}
+}
+
+func (server *Server) handle(name string, m *method) {
+	server.methods[name] = m
+	server.loop.HandleTopic(name, func(ctx *linker.Context) {
+		replied := false
+		defer func() {
+			// 方法panic时先响应错误，panic继续交给OnPanic处理
+			if !replied {
+				server.reply(ctx, nil, errPanic)
+			}
+		}()
+		reply, err := m.call(ctx, ctx.Body())
+		replied = true
+		server.reply(ctx, reply, err)
+	})
+}
+
+// missing 形如"Service.Method"但没有注册为方法或者其他路由的主题，包括未注册的服务
+func (server *Server) missing(ctx *linker.Context) bool {
+	name, ok := ctx.Route().Topic()
+	if !ok {
+		return false
+	}
+	if _, ok = server.methods[name]; ok {
+		return false
+	}
+	service, method, ok := strings.Cut(name, ".")
+	if !ok || service == "" || method == "" {
+		return false
+	}
+	return !server.loop.HasRoute(ctx.Route())
+}
+
+// aborted 在执行方法之前被终止的调用以终止原因响应
+func (server *Server) aborted(ctx *linker.Context) {
+	name, ok := ctx.Route().Topic()
+	if !ok {
+		return
+	}
+	if _, ok = server.methods[name]; !ok && !server.missing(ctx) {
+		return
+	}
+	err := errAborted
+	if errs := ctx.Errors(); len(errs) > 0 {
+		err = errs[len(errs)-1]
+	}
+	server.reply(ctx, nil, err)
+}
+
+func (server *Server) reply(ctx *linker.Context, reply []byte, err error) {
+	var msg []byte
+	if err != nil {
+		msg = append([]byte{statusError}, err.Error()...)
+	} else {
+		msg = append([]byte{statusOK}, reply...)
+	}
+	if err = ctx.Reply(msg); err != nil {
+		log.Printf("rpc: reply to conn(%s) error(%v)", ctx.Conn().ID(), err)
+	}
+}
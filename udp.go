@@ -0,0 +1,171 @@
+package linker
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"linker/pkg/poller"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDPConn 以远端地址区分的虚拟连接，共享监听的socket
+type UDPConn struct {
+	uuid           string
+	listener       *udpListener
+	remote         *net.UDPAddr
+	hash           uint32 // 远端地址的哈希，用于选择SubReactor
+	lastActive     int64  // 最近一次收到数据报的时间(纳秒)
+	lastWrite      int64  // 最近一次发送数据报的时间(纳秒)
+	closed         int32  // 会话关闭后Push返回ErrConnClosed
+	once           sync.Once
+	mu             sync.Mutex // 会话创建后才设置closedCallback，可能与过期清理同时访问
+	closedCallback ConnEvent
+	requests       requests
+}
+
+func (conn *UDPConn) ID() string {
+	return conn.uuid
+}
+
+// FD 返回共享socket的文件描述符
+func (conn *UDPConn) FD() int {
+	return conn.listener.fd
+}
+
+// RemoteAddr 返回远端地址
+func (conn *UDPConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
+
+// Push 向远端发送一个数据报，会话关闭后返回ErrConnClosed
+func (conn *UDPConn) Push(msg []byte) error {
+	if atomic.LoadInt32(&conn.closed) == 1 {
+		return ErrConnClosed
+	}
+	_, err := conn.listener.socket.WriteToUDP(msg, conn.remote)
+	if err == nil {
+		atomic.StoreInt64(&conn.lastWrite, time.Now().UnixNano())
+	}
+	return err
+}
+
+func (conn *UDPConn) Request(ctx context.Context, msg []byte) ([]byte, error) {
+	return conn.requests.do(ctx, conn, msg)
+}
+
+func (conn *UDPConn) resolve(sequence uint32, body []byte) bool {
+	return conn.requests.resolve(sequence, body)
+}
+
+func (conn *UDPConn) pushShared(msg *sharedMessage) error {
+	return conn.Push(msg.body)
+}
+
+// Close 移除会话，不会关闭共享的socket
+func (conn *UDPConn) Close() {
+	conn.once.Do(func() {
+		atomic.StoreInt32(&conn.closed, 1)
+		conn.listener.remove(conn)
+		conn.mu.Lock()
+		callback := conn.closedCallback
+		conn.mu.Unlock()
+		if callback != nil {
+			callback(conn)
+		}
+		conn.requests.close()
+	})
+}
+
+func (conn *UDPConn) onClosed(callback ConnEvent) {
+	conn.mu.Lock()
+	conn.closedCallback = callback
+	conn.mu.Unlock()
+}
+
+func (conn *UDPConn) active(now int64) {
+	atomic.StoreInt64(&conn.lastActive, now)
+}
+
+func (conn *UDPConn) activity() (readAt, writeAt int64) {
+	return atomic.LoadInt64(&conn.lastActive), atomic.LoadInt64(&conn.lastWrite)
+}
+
+func (conn *UDPConn) ping(msg []byte) error {
+	return conn.Push(msg)
+}
+
+func (conn *UDPConn) idle(now int64, timeout time.Duration) bool {
+	return now-atomic.LoadInt64(&conn.lastActive) > int64(timeout)
+}
+
+// udpListener 一个UDP socket及其上的会话
+type udpListener struct {
+	socket *net.UDPConn
+	fd     int
+
+	mu       sync.RWMutex
+	sessions map[string]*UDPConn
+}
+
+func newUDPListener(socket *net.UDPConn) *udpListener {
+	return &udpListener{
+		socket:   socket,
+		fd:       poller.SocketFD(socket),
+		sessions: make(map[string]*UDPConn, 1024),
+	}
+}
+
+// session 获取远端地址对应的会话，不存在时创建
+func (lis *udpListener) session(remote *net.UDPAddr) (conn *UDPConn, created bool) {
+	key := remote.String()
+	lis.mu.RLock()
+	conn = lis.sessions[key]
+	lis.mu.RUnlock()
+	if conn != nil {
+		return
+	}
+
+	lis.mu.Lock()
+	defer lis.mu.Unlock()
+	if conn = lis.sessions[key]; conn != nil {
+		return
+	}
+	conn = &UDPConn{
+		uuid:       uuid.NewV4().String(),
+		listener:   lis,
+		remote:     remote,
+		hash:       shardIndex(key, math.MaxUint32),
+		lastActive: time.Now().UnixNano(), // 避免新会话在收到第一个数据报前被清理
+	}
+	lis.sessions[key] = conn
+	return conn, true
+}
+
+func (lis *udpListener) remove(conn *UDPConn) {
+	key := conn.remote.String()
+	lis.mu.Lock()
+	if lis.sessions[key] == conn {
+		delete(lis.sessions, key)
+	}
+	lis.mu.Unlock()
+}
+
+// expire 关闭超过timeout未收到数据的会话
+func (lis *udpListener) expire(timeout time.Duration) {
+	now := time.Now().UnixNano()
+	expired := make([]*UDPConn, 0)
+	lis.mu.RLock()
+	for _, conn := range lis.sessions {
+		if conn.idle(now, timeout) {
+			expired = append(expired, conn)
+		}
+	}
+	lis.mu.RUnlock()
+
+	for _, conn := range expired {
+		conn.Close()
+	}
+}
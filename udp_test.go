@@ -0,0 +1,101 @@
+package linker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPSession(t *testing.T) {
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer socket.Close()
+	lis := newUDPListener(socket)
+
+	remote := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000}
+	conn, created := lis.session(remote)
+	assert.True(t, created)
+	same, created := lis.session(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000})
+	assert.False(t, created)
+	assert.Equal(t, conn.ID(), same.ID())
+
+	var disconnected int
+	conn.closedCallback = func(conn Conn) {
+		disconnected++
+	}
+	conn.active(time.Now().Add(-time.Minute).UnixNano())
+	lis.expire(time.Second)
+	assert.Equal(t, 1, disconnected)
+
+	_, created = lis.session(remote)
+	assert.True(t, created)
+}
+
+func TestUDPSessionSharding(t *testing.T) {
+	reactor := NewReactor(WithProcessor(4)).(*MainReactor)
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer socket.Close()
+	lis := newUDPListener(socket)
+
+	// 同一个socket上的会话分散到多个SubReactor
+	// 广播时按同样的下标分组并行发送
+	used := make(map[*SubReactor]struct{})
+	for port := 10000; port < 10064; port++ {
+		conn, _ := lis.session(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+		sub := reactor.subReactorOf(conn)
+		assert.Equal(t, sub, reactor.children[reactor.shardOf(conn)])
+		used[sub] = struct{}{}
+	}
+	assert.Greater(t, len(used), 1)
+}
+
+func TestUDPAcceptorNoIdleTimeout(t *testing.T) {
+	acceptor := NewUDPAcceptor(func(conn *UDPConn) {}, func(conn Conn, packet []byte) {}).
+		WithIdleTimeout(0)
+	assert.Nil(t, acceptor.Listen("127.0.0.1:0"))
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, acceptor.Close())
+}
+
+func TestUDPReactor(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2), WithSessionIdleTimeout(100*time.Millisecond))
+	connected := make(chan Conn, 1)
+	disconnected := make(chan Conn, 1)
+	reactor.OnConnect(func(conn Conn) {
+		connected <- conn
+	})
+	reactor.OnDisconnect(func(conn Conn) {
+		disconnected <- conn
+	})
+	reactor.OnRequest(func(ctx *Context) {
+		_ = ctx.Conn().Push(append([]byte("echo:"), ctx.Body()...))
+	})
+	bind, shutdown := startReactor(t, reactor, UDP)
+	defer shutdown()
+
+	c, err := net.Dial("udp", bind)
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("hello"))
+	assert.Nil(t, err)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "echo:hello", string(buf[:n]))
+
+	session := <-connected
+	assert.Equal(t, c.LocalAddr().String(), session.(*UDPConn).RemoteAddr().String())
+
+	// 空闲超时后会话被关闭，之后的Push返回ErrConnClosed
+	select {
+	case conn := <-disconnected:
+		assert.Equal(t, session, conn)
+	case <-time.After(time.Second):
+		t.Fatal("idle session not expired")
+	}
+	assert.Equal(t, ErrConnClosed, session.Push([]byte("late")))
+	assert.Nil(t, reactor.GetConn(session.ID()))
+}
@@ -0,0 +1,334 @@
+package linker
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"linker/pkg/poller"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// websocket opcode, see RFC 6455 section 5.2
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// 控制帧的负载不能超过125字节
+	websocketMaxControlPayload = 125
+
+	closeNormal         = 1000
+	closeProtocolError  = 1002
+	closeInvalidPayload = 1007
+	closeTooBig         = 1009
+)
+
+var (
+	errBadHandshake      = errors.New("websocket: bad handshake")
+	errProtocol          = errors.New("websocket: protocol error")
+	errUnmaskedFrame     = errors.New("websocket: client frame is not masked")
+	errInvalidControl    = errors.New("websocket: invalid control frame")
+	errUnexpectedFrame   = errors.New("websocket: unexpected continuation frame")
+	errInterruptedFrames = errors.New("websocket: data frame interrupts fragmented message")
+	errInvalidUTF8       = errors.New("websocket: invalid UTF-8 in text message")
+)
+
+// WebsocketConn websocket连接，读写均为RFC 6455的数据帧
+type WebsocketConn struct {
+	*Connection
+	fragments []byte // 分片消息的缓存
+	opcode    byte   // 分片消息的类型
+}
+
+// newWebsocketConn 握手时reader中已经缓存的数据帧会写入读缓冲区
+func newWebsocketConn(conn net.Conn, reader *bufio.Reader, opts *options, poll poller.Poller) *WebsocketConn {
+	c := &WebsocketConn{Connection: newConn(conn, opts, poll)}
+	c.owner = c
+	if n := reader.Buffered(); n > 0 {
+		buffered, _ := reader.Peek(n)
+		_, _ = c.inbound.Write(buffered)
+	}
+	return c
+}
+
+// Push 以WithWebsocketMessageType设置的帧类型发送一条消息
+func (conn *WebsocketConn) Push(msg []byte) error {
+	return conn.writeFrame(byte(conn.options.websocketMessage), msg)
+}
+
+// pushShared 广播时多个连接共享同一个编码后的帧
+func (conn *WebsocketConn) pushShared(msg *sharedMessage) error {
+	return conn.enqueue(msg.websocketFrame(byte(conn.options.websocketMessage)), true)
+}
+
+// ping 以ping帧发送心跳，客户端回复的pong帧会刷新读取时间
+func (conn *WebsocketConn) ping(msg []byte) error {
+	return conn.writeFrame(opPing, msg)
+}
+
+// PushBinary 以二进制帧发送一条消息
+func (conn *WebsocketConn) PushBinary(msg []byte) error {
+	return conn.writeFrame(opBinary, msg)
+}
+
+// Close 发送关闭帧后关闭连接
+func (conn *WebsocketConn) Close() {
+	conn.closeWithCode(closeNormal)
+}
+
+func (conn *WebsocketConn) closeWithCode(code uint16) {
+	conn.once.Do(func() {
+		payload := make([]byte, 2)
+		binary.BigEndian.PutUint16(payload, code)
+		// 在读协程中关闭时不能等待发送队列，队列已满时放弃发送关闭帧
+		_ = conn.tryWrite(encodeFrame(opClose, payload))
+		conn.release()
+	})
+}
+
+// read 非阻塞地读取一条完整的消息，socket中暂时没有数据时返回nil
+func (conn *WebsocketConn) read() ([]byte, error) {
+	return conn.readFrame(conn.decodeMessage)
+}
+
+// decodeMessage 从缓冲区中解码一条完整的消息，期间自动处理控制帧与分片
+func (conn *WebsocketConn) decodeMessage() ([]byte, error) {
+	for {
+		fin, opcode, payload, err := conn.decodeFrame()
+		if err != nil {
+			switch err.(type) {
+			case *FrameTooLargeError:
+				conn.closeWithCode(closeTooBig)
+			default:
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					conn.closeWithCode(closeProtocolError)
+				}
+			}
+			return nil, err
+		}
+		if payload == nil {
+			return nil, nil
+		}
+
+		switch opcode {
+		case opPing:
+			_ = conn.tryWrite(encodeFrame(opPong, payload))
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := uint16(closeNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload)
+			}
+			conn.closeWithCode(code)
+			return nil, io.EOF
+		case opText, opBinary:
+			if conn.opcode != 0 {
+				conn.closeWithCode(closeProtocolError)
+				return nil, errInterruptedFrames
+			}
+			if fin {
+				return conn.message(opcode, payload)
+			}
+			conn.opcode = opcode
+			conn.fragments = append(conn.fragments[:0], payload...)
+		case opContinuation:
+			if conn.opcode == 0 {
+				conn.closeWithCode(closeProtocolError)
+				return nil, errUnexpectedFrame
+			}
+			if length := len(conn.fragments) + len(payload); length > conn.options.maxFrameSize {
+				conn.closeWithCode(closeTooBig)
+				return nil, &FrameTooLargeError{Length: length, Limit: conn.options.maxFrameSize}
+			}
+			conn.fragments = append(conn.fragments, payload...)
+			if fin {
+				msg := append(make([]byte, 0, len(conn.fragments)), conn.fragments...)
+				opcode := conn.opcode
+				conn.fragments, conn.opcode = conn.fragments[:0], 0
+				return conn.message(opcode, msg)
+			}
+		default:
+			conn.closeWithCode(closeProtocolError)
+			return nil, errProtocol
+		}
+	}
+}
+
+// message 文本消息必须是合法的UTF-8，否则以1007关闭连接，见RFC 6455 section 8.1
+func (conn *WebsocketConn) message(opcode byte, payload []byte) ([]byte, error) {
+	if opcode == opText && !utf8.Valid(payload) {
+		conn.closeWithCode(closeInvalidPayload)
+		return nil, errInvalidUTF8
+	}
+	return payload, nil
+}
+
+// decodeFrame 从缓冲区中解码一个数据帧并解除掩码，数据帧不完整时payload为nil
+func (conn *WebsocketConn) decodeFrame() (fin bool, opcode byte, payload []byte, err error) {
+	in := conn.inbound
+	buffered := in.Buffered()
+	if buffered < 2 {
+		return
+	}
+	header := peekFull(in, minInt(buffered, 14))
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 { // 未协商扩展，RSV必须为0
+		err = errProtocol
+		return
+	}
+	if header[1]&0x80 == 0 { // 客户端发送的帧必须带掩码
+		err = errUnmaskedFrame
+		return
+	}
+
+	length := uint64(header[1] & 0x7f)
+	headerLength := 2
+	switch length {
+	case 126:
+		headerLength += 2
+	case 127:
+		headerLength += 8
+	}
+	headerLength += 4 // 掩码
+	if len(header) < headerLength {
+		return
+	}
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:10])
+	}
+
+	if opcode&0x8 != 0 && (!fin || length > websocketMaxControlPayload) {
+		err = errInvalidControl
+		return
+	}
+	if length > uint64(conn.options.maxFrameSize) {
+		err = &FrameTooLargeError{Length: int(length), Limit: conn.options.maxFrameSize}
+		return
+	}
+	if uint64(buffered) < uint64(headerLength)+length {
+		return
+	}
+
+	mask := header[headerLength-4 : headerLength]
+	_, _ = in.Discard(headerLength)
+	payload = readFull(in, int(length))
+	maskBytes(mask, payload)
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// writeFrame 写入一个不分片的数据帧，服务端发送的帧不带掩码
+func (conn *WebsocketConn) writeFrame(opcode byte, payload []byte) error {
+	return conn.write(encodeFrame(opcode, payload))
+}
+
+// encodeFrame 将负载编码为一个FIN帧
+func encodeFrame(opcode byte, payload []byte) []byte {
+	length := len(payload)
+	var frame []byte
+	switch {
+	case length <= 125:
+		frame = make([]byte, 2, 2+length)
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame = make([]byte, 4, 4+length)
+		frame[1] = 126
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = make([]byte, 10, 10+length)
+		frame[1] = 127
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	frame[0] = 0x80 | opcode
+	return append(frame, payload...)
+}
+
+func maskBytes(mask []byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i&3]
+	}
+}
+
+// upgrade 完成websocket握手，返回的reader中可能已经缓存了客户端发送的数据帧
+func upgrade(conn net.Conn) (*bufio.Reader, error) {
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method != http.MethodGet ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") {
+		writeHandshakeError(conn, http.StatusBadRequest)
+		return nil, errBadHandshake
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		writeHandshakeError(conn, http.StatusUpgradeRequired)
+		return nil, errBadHandshake
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeHandshakeError(conn, http.StatusBadRequest)
+		return nil, errBadHandshake
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err = conn.Write([]byte(response)); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// acceptKey 计算Sec-WebSocket-Accept
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, v := range header.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeHandshakeError(conn net.Conn, status int) {
+	_, _ = conn.Write([]byte("HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status) + "\r\n\r\n"))
+}
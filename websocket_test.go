@@ -0,0 +1,228 @@
+package linker
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"linker/pkg/poller"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// clientFrame 构造一个客户端发送的带掩码的数据帧
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	frame := encodeFrame(opcode, payload)
+	if !fin {
+		frame[0] &^= 0x80
+	}
+	header := len(frame) - len(payload)
+	mask := []byte{0x1, 0x2, 0x3, 0x4}
+	masked := append([]byte(nil), payload...)
+	maskBytes(mask, masked)
+
+	out := append([]byte(nil), frame[:header]...)
+	out[1] |= 0x80
+	out = append(out, mask...)
+	return append(out, masked...)
+}
+
+// newTestWebsocketConn 客户端发送的数据帧直接写入读缓冲区，服务端的回复由client读取
+func newTestWebsocketConn(t *testing.T) (*WebsocketConn, net.Conn) {
+	server, client := socketPair(t)
+	poll, err := poller.CreateEpoll()
+	assert.Nil(t, err)
+	conn := newWebsocketConn(server, bufio.NewReader(server), defaultOption(), poll)
+	return conn, client
+}
+
+func TestAcceptKey(t *testing.T) {
+	// RFC 6455 section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestWebsocketUpgrade(t *testing.T) {
+	server, client := net.Pipe()
+	go func() {
+		_, _ = client.Write([]byte("GET /chat HTTP/1.1\r\n" +
+			"Host: localhost\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: keep-alive, Upgrade\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+			"Sec-WebSocket-Version: 13\r\n\r\n"))
+	}()
+
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		assert.Nil(t, err)
+		done <- resp
+	}()
+
+	_, err := upgrade(server)
+	assert.Nil(t, err)
+	resp := <-done
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+}
+
+func TestWebsocketReadFragments(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	pong := make(chan []byte)
+	go func() {
+		frame := make([]byte, 6)
+		_, _ = io.ReadFull(client, frame)
+		pong <- frame
+	}()
+
+	_, _ = conn.inbound.Write(clientFrame(false, opText, []byte("hello,")))
+	_, _ = conn.inbound.Write(clientFrame(true, opPing, []byte("ping")))
+	last := clientFrame(true, opContinuation, []byte("world"))
+	_, _ = conn.inbound.Write(last[:4])
+
+	msg, err := conn.decodeMessage()
+	assert.Nil(t, err)
+	assert.Nil(t, msg)
+	assert.Equal(t, encodeFrame(opPong, []byte("ping")), <-pong)
+
+	_, _ = conn.inbound.Write(last[4:])
+	msg, err = conn.decodeMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello,world", string(msg))
+}
+
+func TestWebsocketReadClose(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	_, _ = conn.inbound.Write(clientFrame(true, opClose, []byte{0x03, 0xe8}))
+
+	reply := make(chan []byte)
+	go func() {
+		frame := make([]byte, 4)
+		_, _ = io.ReadFull(client, frame)
+		reply <- frame
+	}()
+
+	_, err := conn.decodeMessage()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, encodeFrame(opClose, []byte{0x03, 0xe8}), <-reply)
+}
+
+func TestWebsocketRejectUnmasked(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	go func() {
+		_, _ = io.Copy(io.Discard, client)
+	}()
+
+	_, _ = conn.inbound.Write(encodeFrame(opText, []byte("hello")))
+	_, err := conn.decodeMessage()
+	assert.Equal(t, errUnmaskedFrame, err)
+}
+
+func TestWebsocketMessageTooBig(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	conn.options.maxFrameSize = 4
+	go func() {
+		_, _ = io.Copy(io.Discard, client)
+	}()
+
+	_, _ = conn.inbound.Write(clientFrame(true, opBinary, []byte("hello")))
+	_, err := conn.decodeMessage()
+	assert.IsType(t, &FrameTooLargeError{}, err)
+}
+
+func TestWebsocketMessageType(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	conn.options.websocketMessage = WebsocketText
+	frame := make(chan []byte)
+	go func() {
+		buf := make([]byte, 4)
+		_, _ = io.ReadFull(client, buf)
+		frame <- buf
+	}()
+	assert.Nil(t, conn.Push([]byte{0x1, 0x2}))
+	assert.Equal(t, encodeFrame(opText, []byte{0x1, 0x2}), <-frame)
+}
+
+func TestWebsocketInvalidUTF8(t *testing.T) {
+	conn, client := newTestWebsocketConn(t)
+	reply := make(chan []byte)
+	go func() {
+		frame := make([]byte, 4)
+		_, _ = io.ReadFull(client, frame)
+		reply <- frame
+	}()
+
+	// 分片的文本消息在拼接完成后校验
+	_, _ = conn.inbound.Write(clientFrame(false, opText, []byte{'a', 0xe4}))
+	_, _ = conn.inbound.Write(clientFrame(true, opContinuation, []byte{0xff}))
+	_, err := conn.decodeMessage()
+	assert.Equal(t, errInvalidUTF8, err)
+	assert.Equal(t, encodeFrame(opClose, []byte{0x03, 0xef}), <-reply)
+}
+
+func TestWebsocketCloseQueueFull(t *testing.T) {
+	conn, _ := newTestWebsocketConn(t)
+	conn.options.slowConsumerPolicy = SlowConsumerBlock
+	conn.options.writeHighWaterMark = 4
+	conn.options.writeTimeout = time.Minute
+	conn.outbound.PushBack(make([]byte, 4))
+
+	// 发送队列已满时放弃发送关闭帧，不会阻塞读协程
+	done := make(chan struct{})
+	go func() {
+		conn.closeWithCode(closeProtocolError)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("closeWithCode blocked on a full write queue")
+	}
+}
+
+func TestWebsocketReactor(t *testing.T) {
+	reactor := NewReactor(WithProcessor(2))
+	reactor.OnRequest(func(ctx *Context) {
+		_ = ctx.Conn().Push(append([]byte("echo:"), ctx.Body()...))
+	})
+	bind, shutdown := startReactor(t, reactor, WS)
+	defer shutdown()
+
+	c, err := net.DialTimeout("tcp", bind, time.Second)
+	assert.Nil(t, err)
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+	// 与握手请求一起发送的数据帧同样会被处理
+	_, err = c.Write(append([]byte("GET /chat HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n"), clientFrame(true, opText, []byte("hello"))...))
+	assert.Nil(t, err)
+
+	reader := bufio.NewReader(c)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	readFrame := func() []byte {
+		header := make([]byte, 2)
+		_, err := io.ReadFull(reader, header)
+		assert.Nil(t, err)
+		frame := make([]byte, 2+int(header[1]))
+		copy(frame, header)
+		_, err = io.ReadFull(reader, frame[2:])
+		assert.Nil(t, err)
+		return frame
+	}
+	// 默认以二进制帧发送，与消息内容无关
+	assert.Equal(t, encodeFrame(opBinary, []byte("echo:hello")), readFrame())
+
+	_, _ = c.Write(clientFrame(true, opBinary, []byte{0xff, 0xfe}))
+	assert.Equal(t, encodeFrame(opBinary, []byte("echo:\xff\xfe")), readFrame())
+
+	_, _ = c.Write(clientFrame(true, opClose, []byte{0x03, 0xe8}))
+	assert.Equal(t, encodeFrame(opClose, []byte{0x03, 0xe8}), readFrame())
+}